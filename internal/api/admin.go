@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+// logLevelChange is the body of PUT /admin/loglevel. Level may be "reset" and
+// an empty subsystem changes the global level. Subsystems are the names given
+// to logging.Subsystem (db, bot and ruleset), unknown ones get a 404.
+type logLevelChange struct {
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+}
+
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, logging.Levels())
+}
+
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var change logLevelChange
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&change); err != nil {
+		writeError(w, http.StatusBadRequest, "body must be a JSON object with a level")
+		return
+	}
+
+	if err := logging.ChangeLevel(change.Subsystem, change.Level); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, logging.ErrUnknownSubsystem) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	slog.WarnContext(r.Context(), "Log level changed", "new_level", change.Level, "subsystem", change.Subsystem)
+
+	writeJSON(w, r, logging.Levels())
+}
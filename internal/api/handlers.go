@@ -0,0 +1,400 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+)
+
+// Discord IDs are serialized as strings: they don't fit in a JavaScript number
+
+type guild struct {
+	GuildID   uint64 `json:"guild_id,string"`
+	ChannelID uint64 `json:"channel_id,string"`
+	Ruleset   string `json:"ruleset"`
+}
+
+type breakdown struct {
+	Swinces      int `json:"swinces"`
+	Nominations  int `json:"nominations"`
+	Fulfillments int `json:"fulfillments"`
+	Total        int `json:"total"`
+}
+
+type leaderboardEntry struct {
+	Rank   int    `json:"rank"`
+	UserID uint64 `json:"user_id,string"`
+	breakdown
+}
+
+type leaderboardResponse struct {
+	Season  *int               `json:"season"` // null for the all-time leaderboard
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
+	Entries []leaderboardEntry `json:"entries"`
+}
+
+type userStats struct {
+	Rank int `json:"rank"` // 0 when the user scored nothing
+	breakdown
+}
+
+type userStatsResponse struct {
+	UserID  uint64    `json:"user_id,string"`
+	Season  int       `json:"season"`
+	Current userStats `json:"current"`
+	AllTime userStats `json:"all_time"`
+}
+
+type season struct {
+	Index   int        `json:"index"`
+	Start   *time.Time `json:"start"` // null for season 0
+	End     *time.Time `json:"end"`   // null for the current season
+	Current bool       `json:"current"`
+	Ruleset string     `json:"ruleset"`
+}
+
+type swince struct {
+	SwinceID      string  `json:"swince_id"`
+	ParticipantID uint64  `json:"participant_id,string"`
+	NomineeID     *string `json:"nominee_id"`
+	FulfillmentID *string `json:"fulfillment_id"`
+}
+
+type event struct {
+	EventID      string    `json:"event_id"`
+	Time         time.Time `json:"time"`
+	Verification string    `json:"verification"`
+	Proof        *string   `json:"proof"`
+	Swinces      []swince  `json:"swinces"`
+}
+
+type eventsResponse struct {
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+	Events []event `json:"events"`
+}
+
+var errBadSeason = errors.New("season must be a season index or \"all\"")
+
+// guildParam resolves the {guild} path segment to a configured guild, or
+// picks the default one on routes without it. The client already got an
+// error when ok is false.
+func (s *Server) guildParam(w http.ResponseWriter, r *http.Request) (guildID uint64, ok bool) {
+	if r.PathValue("guild") == "" {
+		return s.defaultGuildID(w, r)
+	}
+
+	guildID, err := strconv.ParseUint(r.PathValue("guild"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "guild ID must be a Discord snowflake")
+		return 0, false
+	}
+	_, err = s.db.GetGuild(r.Context(), guildID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "unknown guild")
+		return 0, false
+	} else if err != nil {
+		internalError(w, r, "Unable to get guild", err)
+		return 0, false
+	}
+	return guildID, true
+}
+
+// defaultGuildID resolves the guild served by the routes predating guilds:
+// the seed guild, or the only one configured
+func (s *Server) defaultGuildID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	guilds, err := s.db.ListGuilds(r.Context())
+	if err != nil {
+		internalError(w, r, "Unable to list guilds", err)
+		return 0, false
+	}
+	for _, g := range guilds {
+		if g.GuildID == s.defaultGuild {
+			return g.GuildID, true
+		}
+	}
+	switch {
+	case len(guilds) == 1 && s.defaultGuild == 0:
+		return guilds[0].GuildID, true
+	case len(guilds) == 0 || s.defaultGuild != 0:
+		writeError(w, http.StatusNotFound, "no default guild, use /api/v1/guilds/{guild}")
+	default:
+		writeError(w, http.StatusBadRequest, "several guilds are configured, use /api/v1/guilds/{guild}")
+	}
+	return 0, false
+}
+
+// seasonParam reads ?season= (the current season when missing). all is set
+// when the caller asked for every season at once.
+func seasonParam(r *http.Request, current int, allowAll bool) (idx int, all bool, err error) {
+	raw := r.URL.Query().Get("season")
+	switch {
+	case raw == "":
+		return current, false, nil
+	case raw == "all" && allowAll:
+		return 0, true, nil
+	}
+	idx, err = strconv.Atoi(raw)
+	if err != nil || idx < 0 || idx > current {
+		return 0, false, errBadSeason
+	}
+	return idx, false, nil
+}
+
+func toBreakdown(b ruleset.Breakdown) breakdown {
+	return breakdown{
+		Swinces:      b.Swinces,
+		Nominations:  b.Nominations,
+		Fulfillments: b.Fulfillments,
+		Total:        b.Total,
+	}
+}
+
+// stats returns a user's rank and breakdown within the given scores
+func stats(scores ruleset.Scores, userID uint64) userStats {
+	s := userStats{breakdown: toBreakdown(scores[userID])}
+	for _, entry := range scores.Leaderboard(0) {
+		if entry.User.ID == userID {
+			s.Rank = entry.Rank
+			break
+		}
+	}
+	return s
+}
+
+func (s *Server) handleGuilds(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.ListGuilds(r.Context())
+	if err != nil {
+		internalError(w, r, "Unable to list guilds", err)
+		return
+	}
+
+	guilds := make([]guild, 0, len(rows))
+	for _, row := range rows {
+		guilds = append(guilds, guild{
+			GuildID:   row.GuildID,
+			ChannelID: row.ChannelID,
+			Ruleset:   row.Ruleset,
+		})
+	}
+	writeJSON(w, r, guilds)
+}
+
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+
+	guildID, ok := s.guildParam(w, r)
+	if !ok {
+		return
+	}
+	p, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	current, err := ruleset.CurrentSeason(ctx, s.db, guildID, now)
+	if err != nil {
+		internalError(w, r, "Unable to find the current season", err)
+		return
+	}
+	idx, all, err := seasonParam(r, current, true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := leaderboardResponse{Limit: p.Limit, Offset: p.Offset}
+	var scores ruleset.Scores
+	if all {
+		scores, err = ruleset.AllTimeScores(ctx, s.db, guildID, now)
+	} else {
+		resp.Season = &idx
+		scores, err = ruleset.SeasonScores(ctx, s.db, guildID, idx, now)
+	}
+	if err != nil {
+		internalError(w, r, "Unable to compute scores", err)
+		return
+	}
+
+	leaderboard := scores.Leaderboard(0)
+	resp.Total = len(leaderboard)
+	start, end := p.bounds(len(leaderboard))
+	resp.Entries = make([]leaderboardEntry, 0, end-start)
+	for _, entry := range leaderboard[start:end] {
+		resp.Entries = append(resp.Entries, leaderboardEntry{
+			Rank:      entry.Rank,
+			UserID:    entry.User.ID,
+			breakdown: toBreakdown(scores[entry.User.ID]),
+		})
+	}
+
+	writeJSON(w, r, resp)
+}
+
+func (s *Server) handleUserStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+
+	guildID, ok := s.guildParam(w, r)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "user ID must be a Discord snowflake")
+		return
+	}
+
+	current, err := ruleset.CurrentSeason(ctx, s.db, guildID, now)
+	if err != nil {
+		internalError(w, r, "Unable to find the current season", err)
+		return
+	}
+	idx, _, err := seasonParam(r, current, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	seasonScores, err := ruleset.SeasonScores(ctx, s.db, guildID, idx, now)
+	if err != nil {
+		internalError(w, r, "Unable to compute scores", err)
+		return
+	}
+	allTimeScores, err := ruleset.AllTimeScores(ctx, s.db, guildID, now)
+	if err != nil {
+		internalError(w, r, "Unable to compute scores", err)
+		return
+	}
+
+	writeJSON(w, r, userStatsResponse{
+		UserID:  userID,
+		Season:  idx,
+		Current: stats(seasonScores, userID),
+		AllTime: stats(allTimeScores, userID),
+	})
+}
+
+func (s *Server) handleSeasons(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	guildID, ok := s.guildParam(w, r)
+	if !ok {
+		return
+	}
+	starts, err := s.db.ListGuildSeasons(ctx, guildID)
+	if err != nil {
+		internalError(w, r, "Unable to list seasons", err)
+		return
+	}
+	current, err := ruleset.CurrentSeason(ctx, s.db, guildID, time.Now())
+	if err != nil {
+		internalError(w, r, "Unable to find the current season", err)
+		return
+	}
+
+	// Season N starts at the (N-1)th start time and ends at the Nth one
+	seasons := make([]season, 0, current+1)
+	for idx := 0; idx <= current; idx++ {
+		rs, err := ruleset.ForSeason(ctx, s.db, guildID, idx)
+		if err != nil {
+			internalError(w, r, "Unable to find the season's ruleset", err)
+			return
+		}
+		entry := season{
+			Index:   idx,
+			Current: idx == current,
+			Ruleset: rs.String(),
+		}
+		if idx > 0 {
+			entry.Start = &starts[idx-1].StartTime
+		}
+		if idx < len(starts) {
+			entry.End = &starts[idx].StartTime
+		}
+		seasons = append(seasons, entry)
+	}
+
+	writeJSON(w, r, seasons)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	guildID, ok := s.guildParam(w, r)
+	if !ok {
+		return
+	}
+	p, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	total, err := s.db.CountEvents(ctx, guildID)
+	if err != nil {
+		internalError(w, r, "Unable to count events", err)
+		return
+	}
+	rows, err := s.db.ListEventsPage(ctx, database.ListEventsPageParams{
+		GuildID: guildID,
+		Limit:   int64(p.Limit),
+		Offset:  int64(p.Offset),
+	})
+	if err != nil {
+		internalError(w, r, "Unable to list events", err)
+		return
+	}
+
+	resp := eventsResponse{
+		Total:  int(total),
+		Limit:  p.Limit,
+		Offset: p.Offset,
+		Events: make([]event, 0, len(rows)),
+	}
+	for _, row := range rows {
+		e := event{
+			EventID:      row.EventID,
+			Time:         row.Time,
+			Verification: row.Verification,
+			Swinces:      []swince{},
+		}
+		if row.Proof.Valid {
+			proof := strconv.FormatInt(row.Proof.Int64, 10)
+			e.Proof = &proof
+		}
+
+		swinces, err := s.db.ListEventSwinces(ctx, row.EventID)
+		if err != nil {
+			internalError(w, r, "Unable to list swinces", err)
+			return
+		}
+		for _, sw := range swinces {
+			entry := swince{
+				SwinceID:      sw.SwinceID,
+				ParticipantID: sw.ParticipantID,
+			}
+			if sw.NomineeID != nil {
+				nominee := strconv.FormatUint(*sw.NomineeID, 10)
+				entry.NomineeID = &nominee
+			}
+			if sw.FulfillmentID.Valid {
+				entry.FulfillmentID = &sw.FulfillmentID.String
+			}
+			e.Swinces = append(e.Swinces, entry)
+		}
+		resp.Events = append(resp.Events, e)
+	}
+
+	writeJSON(w, r, resp)
+}
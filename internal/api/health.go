@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds the time all readiness checks may take together
+const readinessTimeout = 5 * time.Second
+
+// ReadinessCheck reports why a dependency can't serve requests, nil when it can
+type ReadinessCheck func(ctx context.Context) error
+
+type readinessCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+type readinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// AddReadinessCheck makes /readyz fail whenever check does. It must be called
+// before the server starts.
+func (s *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.checks = append(s.checks, readinessCheck{name: name, check: check})
+}
+
+// handleHealth answers as long as the process is able to serve HTTP
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n")) //nolint:errcheck
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := readinessResponse{Status: "ready", Checks: make(map[string]string, len(s.checks))}
+	status := http.StatusOK
+	for _, c := range s.checks {
+		if err := c.check(ctx); err != nil {
+			resp.Checks[c.name] = err.Error()
+			resp.Status, status = "unavailable", http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var errBadPage = errors.New("limit and offset must be non-negative integers")
+
+// page is the pagination requested through ?limit=&offset=
+type page struct {
+	Limit  int
+	Offset int
+}
+
+func parsePage(r *http.Request) (page, error) {
+	p := page{Limit: defaultPageSize}
+	for name, dst := range map[string]*int{"limit": &p.Limit, "offset": &p.Offset} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return page{}, errBadPage
+		}
+		*dst = n
+	}
+	if p.Limit == 0 || p.Limit > maxPageSize {
+		p.Limit = maxPageSize
+	}
+	return p, nil
+}
+
+// bounds returns the slice indexes of the page within n items
+func (p page) bounds(n int) (int, int) {
+	start := min(p.Offset, n)
+	return start, min(start+p.Limit, n)
+}
+
+// writeJSON sends v with an ETag derived from its content. Clients sending a
+// matching If-None-Match get an empty 304 instead.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Unable to encode API response", logging.ErrKey, err)
+		writeError(w, http.StatusInternalServerError, "unable to encode response")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body) //nolint:errcheck
+}
+
+// etagMatches checks an If-None-Match header (weak comparison)
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg}) //nolint:errcheck
+}
+
+// internalError logs err and hides its details from the client
+func internalError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	slog.ErrorContext(r.Context(), msg, logging.ErrKey, err)
+	writeError(w, http.StatusInternalServerError, msg)
+}
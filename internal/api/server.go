@@ -0,0 +1,106 @@
+// Package api serves a read-only JSON view of scores, seasons and events
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// Server is the HTTP listener exposing the /api/v1 endpoints. Everything but
+// the guild list is scoped to a guild, the routes predating guilds serving the
+// default one. gRPC calls share the same port: they are recognized by their
+// content type.
+type Server struct {
+	db           *database.ProtoDB
+	defaultGuild uint64 // 0 when there's no seed guild
+	http         *http.Server
+	grpc         *grpc.Server // nil when gRPC isn't served
+	checks       []readinessCheck
+}
+
+// New prepares (but does not start) a server listening on the given port.
+// Routes without a guild serve defaultGuild, or the only guild when it's 0.
+// grpcServer and metrics are optional, API requests must pass the guard.
+func New(db *database.ProtoDB, port, defaultGuild uint64, grpcServer *grpc.Server, guard *auth.Guard, metrics http.Handler) *Server {
+	s := &Server{
+		db:           db,
+		defaultGuild: defaultGuild,
+		grpc:         grpcServer,
+	}
+
+	mux := http.NewServeMux()
+	read := func(h http.HandlerFunc) http.Handler { return guard.HTTP(auth.ScopeRead, h) }
+	mux.Handle("GET /api/v1/guilds", read(s.handleGuilds))
+	mux.Handle("GET /api/v1/guilds/{guild}/leaderboard", read(s.handleLeaderboard))
+	mux.Handle("GET /api/v1/guilds/{guild}/users/{id}/stats", read(s.handleUserStats))
+	mux.Handle("GET /api/v1/guilds/{guild}/seasons", read(s.handleSeasons))
+	mux.Handle("GET /api/v1/guilds/{guild}/events", read(s.handleEvents))
+	mux.Handle("GET /api/v1/leaderboard", read(s.handleLeaderboard))
+	mux.Handle("GET /api/v1/users/{id}/stats", read(s.handleUserStats))
+	mux.Handle("GET /api/v1/seasons", read(s.handleSeasons))
+	mux.Handle("GET /api/v1/events", read(s.handleEvents))
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	if metrics != nil {
+		mux.Handle("GET /metrics", metrics)
+	}
+	mux.Handle("GET /admin/loglevel", guard.HTTP(auth.ScopeAdmin, http.HandlerFunc(s.handleGetLogLevel)))
+	mux.Handle("PUT /admin/loglevel", guard.HTTP(auth.ScopeAdmin, http.HandlerFunc(s.handleSetLogLevel)))
+
+	s.http = &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           h2c.NewHandler(s.route(mux), &http2.Server{}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// route sends gRPC calls to the gRPC server and everything else to the API.
+// Plain-text HTTP/2 (h2c) is accepted since gRPC clients don't downgrade.
+func (s *Server) route(api http.Handler) http.Handler {
+	if s.grpc == nil {
+		return api
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			s.grpc.ServeHTTP(w, r)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe blocks until the server stops. It returns nil once Shutdown
+// was called.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	s.http.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	slog.InfoContext(ctx, "HTTP API listening", "addr", s.http.Addr)
+	err := s.http.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for active requests.
+// gRPC calls are cut short: GracefulStop isn't supported when gRPC is served
+// through net/http, and streams would otherwise never end.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.grpc != nil {
+		s.grpc.Stop()
+	}
+	return s.http.Shutdown(ctx)
+}
@@ -2,90 +2,114 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/ChausseBenjamin/swincebot/internal/api"
+	"github.com/ChausseBenjamin/swincebot/internal/archive"
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
 	"github.com/ChausseBenjamin/swincebot/internal/bot"
 	"github.com/ChausseBenjamin/swincebot/internal/database"
 	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/lifecycle"
 	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/ChausseBenjamin/swincebot/internal/rpc"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
 	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/tracing"
 	"github.com/ChausseBenjamin/swincebot/internal/util"
 	"github.com/urfave/cli/v3"
 )
 
-func action(ctx context.Context, cmd *cli.Command) error {
-	err := logging.Setup(
-		cmd.String(FlagLogLevel),
-		cmd.String(FlagLogFormat),
-		cmd.String(FlagLogOutput),
-	)
+// before runs ahead of the root action and every subcommand but healthcheck
+func before(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	// Probes run every few seconds: they must neither need the config file
+	// nor open (and rotate) the bot's log files
+	if cmd.Args().First() == healthcheckName {
+		return ctx, nil
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, util.ConfigKey, cfg)
+
+	err = logging.Setup(logging.Config{
+		Level:   cmd.String(FlagLogLevel),
+		Format:  cmd.String(FlagLogFormat),
+		Outputs: cmd.StringSlice(FlagLogOutput),
+		Rotation: logging.Rotation{
+			MaxSize:    int(cmd.Uint(FlagLogMaxSize)),
+			Interval:   cmd.Duration(FlagLogRotateInterval),
+			MaxBackups: int(cmd.Uint(FlagLogMaxBackups)),
+			MaxAge:     cmd.Duration(FlagLogMaxAge),
+			Compress:   cmd.Bool(FlagLogCompress),
+		},
+	})
 	if err != nil {
 		slog.WarnContext(ctx, "Error(s) occurred during logger initialization",
 			logging.ErrKey, err,
 		)
 	}
+	return ctx, nil
+}
 
-	errAppChan := make(chan error)
-	shutdownDone := make(chan struct{})
-
-	var once sync.Once
-	var db *database.ProtoDB
-	gracefulShutdown := func() {}
-
-	application := func() {
-		var err error
-		db, err = initApp(ctx, cmd)
-		if err != nil {
-			errAppChan <- err
-			return
-		}
-
-		slog.InfoContext(ctx, "Starting application server")
-
-		gracefulShutdown = func() {
-			once.Do(func() {
-				db.DB.Close()
-				db.Queries.Close()
-				slog.InfoContext(ctx, "Application shutdown")
-				close(shutdownDone)
-			})
-		}
-
-		slog.InfoContext(ctx, "Application listening")
-	}
-	go application()
+func action(ctx context.Context, cmd *cli.Command) error {
+	failed := make(chan error, 1)
+	m := &lifecycle.Manager{}
+	(&services{}).register(m, cmd, failed)
 
 	stopChan := waitForTermChan()
-	running := true
-	for running {
-		select {
-		case errApp := <-errAppChan:
-			if errApp != nil {
-				slog.ErrorContext(ctx, "Application error", logging.ErrKey, errApp)
-			}
-			return errApp
-		case <-stopChan:
-			slog.InfoContext(ctx, "Shutdown requested")
-			go gracefulShutdown()
+	go logging.StepOnSignals(ctx)
+	grace := cmd.Duration(FlagGraceTimeout)
 
+	startCtx, cancelStart := context.WithCancel(ctx)
+	defer cancelStart()
+	started := make(chan error, 1)
+	go func() { started <- m.Start(startCtx) }()
+
+	var err error
+	select {
+	case err = <-started:
+		if err == nil {
+			slog.InfoContext(ctx, "Application listening")
 			select {
-			case <-time.After(cmd.Duration(FlagGraceTimeout)):
-				slog.WarnContext(ctx, "Graceful shutdown delay exceeded, shutting down NOW!")
-				if db != nil {
-					go db.DB.Close()
-					go db.Queries.Close()
-				}
-			case <-shutdownDone:
+			case err = <-failed:
+			case <-stopChan:
+				slog.InfoContext(ctx, "Shutdown requested")
 			}
-			running = false
+		}
+	case <-stopChan:
+		slog.InfoContext(ctx, "Shutdown requested during startup")
+		cancelStart()
+		select {
+		case <-started:
+		case <-time.After(grace):
+			// Stop can't run alongside a Start that is still going
+			slog.WarnContext(ctx, "Graceful shutdown delay exceeded, shutting down NOW!")
+			return nil
 		}
 	}
-	return nil
+	if err != nil {
+		slog.ErrorContext(ctx, "Application error", logging.ErrKey, err)
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), grace)
+	defer cancel()
+	if stopErr := m.Stop(stopCtx); stopErr != nil {
+		slog.WarnContext(ctx, "Graceful shutdown incomplete", logging.ErrKey, stopErr)
+	} else {
+		slog.InfoContext(ctx, "Application shutdown")
+	}
+	return err
 }
 
 func waitForTermChan() chan os.Signal {
@@ -94,41 +118,267 @@ func waitForTermChan() chan os.Signal {
 	return stopChan
 }
 
-func initApp(ctx context.Context, cmd *cli.Command) (*database.ProtoDB, error) {
-	globalConf := &util.ConfigStore{
-		DBCacheSize: int(-cmd.Uint(FlagDBCacheSize)),
+// setupTracing starts exporting spans when an exporter was chosen
+func setupTracing(cmd *cli.Command) error {
+	switch cmd.String(FlagTraceExporter) {
+	case "otlp":
+		tracing.Enable(tracing.NewOTLPExporter(cmd.String(FlagTraceEndpoint)))
+	case "file":
+		e, err := tracing.NewFileExporter(cmd.String(FlagTraceFile))
+		if err != nil {
+			return err
+		}
+		tracing.Enable(e)
 	}
+	return nil
+}
 
-	db, err := database.Setup(ctx, cmd.String(FlagDBPath), globalConf)
-	if err != nil {
-		return nil, err
+// openDB opens the database described by the flags (shared by every subcommand)
+func openDB(ctx context.Context, cmd *cli.Command) (*database.ProtoDB, error) {
+	db, err := database.Setup(ctx, cmd.String(FlagDBPath), &util.ConfigStore{
+		DBCacheSize:   int(-cmd.Uint(FlagDBCacheSize)),
+		SeedGuildID:   cmd.Uint(FlagDiscordServer),
+		SeedChannelID: cmd.Uint(FlagDiscordChannel),
+		SeedAdmins:    database.FormatAdmins(cmd.UintSlice(FlagDiscordAdmins)),
+	})
+	if errors.Is(err, database.ErrSeedGuildNeeded) {
+		return nil, fmt.Errorf("%w: set --%s and --%s to upgrade", err, FlagDiscordServer, FlagDiscordChannel)
 	}
+	return db, err
+}
 
-	// Initialize secrets vault
-	vault, err := secrets.NewDirVault(cmd.String(FlagSecretsPath))
-	if err != nil {
-		return nil, err
-	}
+// services are the long-lived pieces of the bot, set as they get started
+type services struct {
+	db      *database.ProtoDB
+	vault   secrets.SecretVault
+	discord *discord.Client
+	bot     *bot.Bot
+	tokens  *auth.Issuer
+	server  *api.Server
+}
+
+// register adds the services to m so each one stops before what it uses.
+// Errors of the HTTP server, once listening, are sent to failed.
+func (sv *services) register(m *lifecycle.Manager, cmd *cli.Command, failed chan<- error) {
+	m.Add(lifecycle.Component{
+		Name:  "tracing",
+		Start: func(context.Context) error { return setupTracing(cmd) },
+		Stop:  tracing.Shutdown,
+	})
+
+	m.Add(lifecycle.Component{
+		Name:  "database",
+		Start: sv.startDB(cmd),
+		Stop: func(context.Context) error {
+			return errors.Join(sv.db.Queries.Close(), sv.db.DB.Close())
+		},
+	})
+
+	m.Add(lifecycle.Component{
+		Name: "secrets",
+		Start: func(context.Context) (err error) {
+			sv.vault, err = openVault(cmd)
+			return err
+		},
+	})
+
+	// The bot starts without waiting for Discord so the HTTP API serves
+	// even when the gateway is unreachable
+	var stopConnecting context.CancelFunc
+	m.Add(lifecycle.Component{
+		Name: "discord",
+		Start: func(ctx context.Context) (err error) {
+			sv.discord, err = discord.New(sv.vault)
+			if err != nil {
+				return err
+			}
+			backoff := discord.Backoff{
+				Initial: cmd.Duration(FlagDiscordRetryMin),
+				Max:     cmd.Duration(FlagDiscordRetryMax),
+			}
+			ctx, stopConnecting = context.WithCancel(context.WithoutCancel(ctx))
+			// Connect only gives up once stopped
+			go sv.discord.Connect(ctx, backoff) //nolint:errcheck
+			return nil
+		},
+		Stop: func(context.Context) error {
+			stopConnecting()
+			return sv.discord.Close()
+		},
+	})
+
+	// Stopping the bot drains interactions while the session is still open
+	m.Add(lifecycle.Component{
+		Name:  "bot",
+		Start: sv.startBot(cmd),
+		Stop:  func(ctx context.Context) error { return sv.bot.Shutdown(ctx) },
+	})
 
-	// Initialize Discord client
-	discordClient, err := discord.NewClient(
-		ctx,
-		cmd.Uint(FlagDiscordServer),
-		cmd.Uint(FlagDiscordChannel),
-		vault,
+	// Reminders stop before the bot closes the outbox they go through
+	var (
+		stopReminders context.CancelFunc
+		remindersDone chan struct{}
 	)
-	if err != nil {
-		return nil, err
+	m.Add(lifecycle.Component{
+		Name: "nomination reminders",
+		Start: func(ctx context.Context) error {
+			if cmd.Duration(FlagNominationReminder) <= 0 {
+				return nil
+			}
+			ctx, stopReminders = context.WithCancel(context.WithoutCancel(ctx))
+			remindersDone = make(chan struct{})
+			go func() {
+				defer close(remindersDone)
+				sv.bot.RemindNominations(ctx)
+			}()
+			return nil
+		},
+		Stop: func(context.Context) error {
+			if stopReminders != nil {
+				stopReminders()
+				<-remindersDone
+			}
+			return nil
+		},
+	})
+
+	// Reports stop first so the last ones still get through the outbox
+	var stopReports func()
+	m.Add(lifecycle.Component{
+		Name: "error reports",
+		Start: func(context.Context) error {
+			if cmd.Uint(FlagReportChannel) == 0 && cmd.Uint(FlagReportUser) == 0 {
+				return nil
+			}
+			stopReports = logging.ReportErrors(sv.bot.ReportError, cmd.Duration(FlagReportInterval))
+			return nil
+		},
+		Stop: func(context.Context) error {
+			if stopReports != nil {
+				stopReports()
+			}
+			return nil
+		},
+	})
+
+	// Reconnect with the new bot token whenever it gets rotated in the vault
+	var stopWatching context.CancelFunc
+	m.Add(lifecycle.Component{
+		Name: "secrets watcher",
+		Start: func(ctx context.Context) error {
+			w, ok := sv.vault.(secrets.Watcher)
+			if !ok || cmd.Duration(FlagSecretsPoll) <= 0 {
+				return nil
+			}
+			ctx, stopWatching = context.WithCancel(context.WithoutCancel(ctx))
+			go sv.discord.WatchToken(ctx, sv.vault, w.Watch(ctx, cmd.Duration(FlagSecretsPoll)))
+			return nil
+		},
+		Stop: func(context.Context) error {
+			if stopWatching != nil {
+				stopWatching()
+			}
+			return nil
+		},
+	})
+
+	m.Add(lifecycle.Component{
+		Name:  "http",
+		Start: sv.startServer(cmd, failed),
+		Stop:  func(ctx context.Context) error { return sv.server.Shutdown(ctx) },
+	})
+}
+
+func (sv *services) startDB(cmd *cli.Command) lifecycle.Hook {
+	return func(ctx context.Context) error {
+		db, err := openDB(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		sv.db = db
+
+		// A failed Start isn't stopped: close what was opened
+		if err := seedGuild(ctx, cmd, db); err != nil {
+			db.DB.Close()
+			return err
+		}
+		guilds, err := db.ListGuilds(ctx)
+		if err != nil {
+			db.DB.Close()
+			return fmt.Errorf("listing guilds: %w", err)
+		}
+		if len(guilds) == 0 {
+			slog.InfoContext(ctx, "No guild configured yet, run /setup on a server to get started")
+		}
+		return nil
 	}
+}
 
-	// Initialize bot with slash commands
-	_, err = bot.NewBot(ctx, discordClient, cmd.Uint(FlagDiscordServer), cmd.Uint(FlagDiscordChannel))
-	if err != nil {
-		discordClient.Close()
-		return nil, err
+func (sv *services) startBot(cmd *cli.Command) lifecycle.Hook {
+	return func(ctx context.Context) error {
+		ruleset.InitializeRulesets(cmd.Duration(FlagVerifyWindow))
+
+		proofMaxSize := int64(cmd.Uint(FlagProofMaxSize)) << 20
+
+		var archiver *archive.Archiver
+		if dir := cmd.String(FlagProofArchive); dir != "" {
+			var err error
+			archiver, err = archive.New(dir, proofMaxSize)
+			if err != nil {
+				return err
+			}
+		}
+
+		tokens, err := auth.NewIssuer(sv.db, sv.vault, cmd.Duration(FlagKeyRotation), cmd.Duration(FlagTokenTTL))
+		if err != nil {
+			return fmt.Errorf("loading API token keys: %w", err)
+		}
+		sv.tokens = tokens
+
+		// Initialize bot with slash commands
+		sv.bot, err = bot.NewBot(ctx, sv.discord, sv.db, bot.Config{
+			ConversationTimeout: cmd.Duration(FlagConversationTimeout),
+			ProofMaxSize:        proofMaxSize,
+			VerificationQuorum:  cmd.Uint(FlagVerifyQuorum),
+			NominationDeadline:  cmd.Duration(FlagNominationDeadline),
+			NominationReminder:  cmd.Duration(FlagNominationReminder),
+			Archiver:            archiver,
+			Tokens:              tokens,
+			Operators:           cmd.UintSlice(FlagDiscordOperators),
+			ReportChannelID:     cmd.Uint(FlagReportChannel),
+			ReportUserID:        cmd.Uint(FlagReportUser),
+		})
+		if err != nil {
+			return err
+		}
+
+		slog.InfoContext(ctx, "Bot initialized successfully")
+		return nil
 	}
+}
+
+func (sv *services) startServer(cmd *cli.Command, failed chan<- error) lifecycle.Hook {
+	return func(ctx context.Context) error {
+		slog.InfoContext(ctx, "Starting application server")
+
+		var metricsHandler http.Handler
+		if cmd.Bool(FlagMetrics) {
+			watchNominations(sv.db, cmd.Duration(FlagNominationDeadline))
+			metricsHandler = metrics.Handler()
+		}
 
-	slog.InfoContext(ctx, "Bot initialized successfully")
+		guard := auth.NewGuard(sv.tokens, cmd.Bool(FlagAnonymousReads))
+		sv.server = api.New(sv.db, cmd.Uint(FlagListenPort), cmd.Uint(FlagDiscordServer), rpc.NewServer(sv.db, sv.bot, guard), guard, metricsHandler)
+		sv.server.AddReadinessCheck("database", sv.db.Ready)
+		sv.server.AddReadinessCheck("discord", sv.bot.Ready)
 
-	return db, nil
+		// Requests must outlive the startup context
+		serveCtx := context.WithoutCancel(ctx)
+		go func() {
+			if err := sv.server.ListenAndServe(serveCtx); err != nil {
+				failed <- fmt.Errorf("HTTP API: %w", err)
+			}
+		}()
+		return nil
+	}
 }
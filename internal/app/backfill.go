@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/backfill"
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/transfer"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagBackfillInput  = "input"
+	FlagBackfillFormat = "format"
+	FlagBackfillDryRun = "dry-run"
+)
+
+func backfillCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "backfill",
+		Usage: "Import swinces tracked by hand (spreadsheet) or posted before the bot existed (channel dump)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     FlagBackfillInput,
+				Usage:    "File to import",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  FlagBackfillFormat,
+				Usage: "sheet-csv (date,participants,nominees,fulfills), sheet-json, channel (JSON array of Discord messages)",
+				Value: "sheet-csv",
+				Action: func(ctx context.Context, cmd *cli.Command, s string) error {
+					switch s {
+					case "sheet-csv", "sheet-json", "channel":
+						return nil
+					}
+					return fmt.Errorf("unknown backfill format: %s", s)
+				},
+			},
+			&cli.BoolFlag{
+				Name:  FlagBackfillDryRun,
+				Usage: "Print the conflict report without writing anything",
+			},
+		},
+		Action: backfillAction,
+	}
+}
+
+func backfillAction(ctx context.Context, cmd *cli.Command) error {
+	f, err := os.Open(cmd.String(FlagBackfillInput))
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var read func(io.Reader) ([]backfill.Record, error)
+	switch cmd.String(FlagBackfillFormat) {
+	case "sheet-json":
+		read = backfill.ReadSheetJSON
+	case "channel":
+		read = backfill.ReadChannelDump
+	default:
+		read = backfill.ReadSheetCSV
+	}
+	records, err := read(f)
+	if err != nil {
+		return err
+	}
+
+	guildID, err := commandGuild(cmd)
+	if err != nil {
+		return err
+	}
+
+	members, err := backfillMembers(ctx, cmd, guildID)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	events, err := db.GetEvents(ctx)
+	if err != nil {
+		return fmt.Errorf("reading events: %w", err)
+	}
+	existing := make([]time.Time, 0, len(events))
+	for _, e := range events {
+		if e.GuildID == guildID {
+			existing = append(existing, e.Time)
+		}
+	}
+
+	dump, report := backfill.Build(
+		guildID,
+		records,
+		backfill.NewDirectory(members),
+		cmd.Duration(FlagNominationDeadline),
+		existing,
+	)
+	fmt.Fprint(cmd.Root().Writer, report.String())
+
+	dryRun := cmd.Bool(FlagBackfillDryRun)
+	if err := transfer.Import(ctx, db, dump, dryRun); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Backfill complete", "dry_run", dryRun, "events", report.Events, "conflicts", len(report.Conflicts))
+	return nil
+}
+
+// backfillMembers fetches the server's member list to resolve names
+func backfillMembers(ctx context.Context, cmd *cli.Command, guildID uint64) ([]discord.User, error) {
+	vault, err := openVault(cmd)
+	if err != nil {
+		return nil, err
+	}
+	client, err := discord.NewClient(ctx, vault)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close() //nolint:errcheck
+
+	return client.GetMembers(guildID)
+}
@@ -18,6 +18,21 @@ func Command() *cli.Command {
 		Authors: []any{"Benjamin Chausse <benjamin@chausse.xyz>"},
 		Version: version,
 		Flags:   flags(),
+		Before:  before,
 		Action:  action,
+		Commands: []*cli.Command{
+			simulateCommand(),
+			exportCommand(),
+			importCommand(),
+			backfillCommand(),
+			secretsCommand(),
+			guildsCommand(),
+			configCommand(),
+			dbCommand(),
+			eventsCommand(),
+			seasonsCommand(),
+			scoreCommand(),
+			healthcheckCommand(),
+		},
 	}
 }
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// secretFlags never get printed in clear
+var secretFlags = []string{FlagSecretsPassphrase}
+
+// configFile is the YAML file given with --config. Its keys are the names of
+// the global flags, ex:
+//
+//	log-level: debug
+//	verification-quorum: 3
+//	secrets-backend: [env, dir]
+type configFile struct {
+	path    string
+	applied map[string]bool // flags whose value comes from the file
+}
+
+// loadConfig fills the global flags which weren't set on the command line or
+// through env vars with the values of the config file. Every problem in the
+// file is reported at once.
+func loadConfig(cmd *cli.Command) (*configFile, error) {
+	cfg := &configFile{path: cmd.String(FlagConfig), applied: make(map[string]bool)}
+	if cfg.path == "" {
+		return cfg, nil
+	}
+
+	buf, err := os.ReadFile(cfg.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", cfg.path, err)
+	}
+	if len(doc.Content) == 0 {
+		return cfg, nil // empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s: expected a mapping of flag names to values (line %d)", cfg.path, root.Line)
+	}
+
+	var problems []error
+	problem := func(node *yaml.Node, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("line %d: %s", node.Line, fmt.Sprintf(format, args...)))
+	}
+
+	for i := 0; i < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+		name := key.Value
+
+		fl := globalFlag(cmd, name)
+		switch {
+		case fl == nil:
+			problem(key, "unknown setting %q", name)
+			continue
+		case name == FlagConfig:
+			problem(key, "%q can't be set from the config file", name)
+			continue
+		}
+
+		values, err := configValues(fl, val)
+		if err != nil {
+			problem(val, "%s: %v", name, err)
+			continue
+		}
+		if cmd.IsSet(name) {
+			continue // flags and env vars take precedence
+		}
+		cfg.applied[name] = true
+		for _, v := range values {
+			if err := cmd.Set(name, v); err != nil {
+				problem(val, "%s: %v", name, err)
+				break
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("config file %s is invalid: %w", cfg.path, errors.Join(problems...))
+	}
+	return cfg, nil
+}
+
+// globalFlag finds a flag of the root command by name
+func globalFlag(cmd *cli.Command, name string) cli.Flag {
+	for _, fl := range cmd.Root().Flags {
+		if slices.Contains(fl.Names(), name) {
+			return fl
+		}
+	}
+	return nil
+}
+
+// configValues checks the shape of a value against its flag: lists for flags
+// taking several values, a single scalar otherwise
+func configValues(fl cli.Flag, node *yaml.Node) ([]string, error) {
+	multi := false
+	if mv, ok := fl.(interface{ IsMultiValueFlag() bool }); ok {
+		multi = mv.IsMultiValueFlag()
+	}
+
+	switch {
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!null":
+		return nil, errors.New("missing value")
+	case node.Kind == yaml.ScalarNode && !multi:
+		return []string{node.Value}, nil
+	case node.Kind == yaml.SequenceNode && multi:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("list items must be plain values (line %d)", item.Line)
+			}
+			values = append(values, item.Value)
+		}
+		return values, nil
+	case multi:
+		return nil, errors.New("expected a list")
+	default:
+		return nil, errors.New("expected a single value")
+	}
+}
+
+func configCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Inspect the configuration",
+		Commands: []*cli.Command{
+			{
+				Name:   "print",
+				Usage:  "Show the effective configuration and where each value comes from",
+				Action: configPrintAction,
+			},
+		},
+	}
+}
+
+func configPrintAction(ctx context.Context, cmd *cli.Command) error {
+	cfg := util.GetFromContext[configFile](ctx, util.ConfigKey)
+	if cfg == nil {
+		cfg = &configFile{}
+	}
+	cmdLine := commandLineFlags(os.Args[1:])
+
+	tw := tabwriter.NewWriter(cmd.Root().Writer, 0, 4, 2, ' ', 0)
+	for _, fl := range cmd.Root().Flags {
+		name := fl.Names()[0]
+		if name == FlagConfig || name == "help" || name == "version" {
+			continue
+		}
+
+		var source string
+		switch {
+		case cfg.applied[name]:
+			source = "config file " + cfg.path
+		case !cmd.IsSet(name):
+			source = "default"
+		case cmdLine[name]:
+			source = "command line"
+		default:
+			source = "env " + setEnvVar(fl)
+		}
+
+		value := formatConfigValue(cmd.Value(name))
+		if slices.Contains(secretFlags, name) && value != `""` {
+			value = secrets.Secret(value).LogValue().String()
+		}
+		fmt.Fprintf(tw, "%s: %s\t# %s\n", name, value, source)
+	}
+	return tw.Flush()
+}
+
+// commandLineFlags lists the flag names present in the arguments
+func commandLineFlags(args []string) map[string]bool {
+	names := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		names[name] = true
+	}
+	return names
+}
+
+// setEnvVar is the first env var of a flag which is set
+func setEnvVar(fl cli.Flag) string {
+	if ev, ok := fl.(interface{ GetEnvVars() []string }); ok {
+		for _, key := range ev.GetEnvVars() {
+			if _, found := os.LookupEnv(key); found {
+				return key
+			}
+		}
+	}
+	return "(unknown)"
+}
+
+// formatConfigValue writes values the way the config file expects them
+func formatConfigValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return `""`
+		}
+		return v
+	case time.Duration:
+		return v.String()
+	case []string:
+		return "[" + strings.Join(v, ", ") + "]"
+	case []uint64:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/urfave/cli/v3"
+)
+
+var errCheckFailed = errors.New("database check failed")
+
+func dbCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "db",
+		Usage: "Maintain the database",
+		Commands: []*cli.Command{
+			{
+				Name:   "check",
+				Usage:  "Report integrity, schema and orphaned fulfillment problems without fixing them",
+				Action: dbCheckAction,
+			},
+			{
+				Name:   "vacuum",
+				Usage:  "Reclaim the space left by deleted rows",
+				Action: dbVacuumAction,
+			},
+		},
+	}
+}
+
+func dbCheckAction(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.String(FlagDBPath)
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	problems, err := database.Check(ctx, path)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Fprintln(cmd.Root().Writer, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %d problem(s)", errCheckFailed, len(problems))
+	}
+
+	slog.InfoContext(ctx, "Database is healthy", "path", path)
+	return nil
+}
+
+func dbVacuumAction(ctx context.Context, cmd *cli.Command) error {
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	before, err := os.Stat(cmd.String(FlagDBPath))
+	if err != nil {
+		return err
+	}
+	if err := db.Vacuum(ctx); err != nil {
+		return err
+	}
+	after, err := os.Stat(cmd.String(FlagDBPath))
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Database vacuumed", "size_before", before.Size(), "size_after", after.Size())
+	return nil
+}
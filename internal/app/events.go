@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagEventsLimit  = "limit"
+	FlagEventsOffset = "offset"
+)
+
+func eventsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "events",
+		Usage: "Inspect and correct recorded swince events",
+		Commands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List the server's events, most recent first",
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:  FlagEventsLimit,
+						Usage: "Number of events to show",
+						Value: 20,
+					},
+					&cli.UintFlag{
+						Name:  FlagEventsOffset,
+						Usage: "Number of recent events to skip",
+					},
+				},
+				Action: eventsListAction,
+			},
+			{
+				Name:      "show",
+				Usage:     "Show an event with its swinces and votes",
+				ArgsUsage: "<event-id>",
+				Action:    eventsShowAction,
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete an event along with its swinces, votes and archive record",
+				ArgsUsage: "<event-id>",
+				Action:    eventsDeleteAction,
+			},
+		},
+	}
+}
+
+// eventArg is the event ID given as the only argument of a subcommand
+func eventArg(cmd *cli.Command) (string, error) {
+	if cmd.Args().Len() != 1 {
+		return "", errors.New("expected exactly one event ID")
+	}
+	return cmd.Args().First(), nil
+}
+
+// getGuild fetches a configured server
+func getGuild(ctx context.Context, db *database.ProtoDB, guildID uint64) (database.Guild, error) {
+	guild, err := db.GetGuild(ctx, guildID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return guild, fmt.Errorf("guild %d isn't configured", guildID)
+	} else if err != nil {
+		return guild, fmt.Errorf("getting guild: %w", err)
+	}
+	return guild, nil
+}
+
+// getEvent fetches an event along with the server it belongs to
+func getEvent(ctx context.Context, db *database.ProtoDB, eventID string) (database.Event, database.Guild, error) {
+	event, err := db.GetEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return event, database.Guild{}, fmt.Errorf("no event with ID %s", eventID)
+	} else if err != nil {
+		return event, database.Guild{}, fmt.Errorf("getting event: %w", err)
+	}
+	guild, err := getGuild(ctx, db, event.GuildID)
+	return event, guild, err
+}
+
+func eventsListAction(ctx context.Context, cmd *cli.Command) error {
+	guildID, err := commandGuild(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	guild, err := getGuild(ctx, db, guildID)
+	if err != nil {
+		return err
+	}
+	events, err := db.ListEventsPage(ctx, database.ListEventsPageParams{
+		GuildID: guildID,
+		Limit:   int64(cmd.Uint(FlagEventsLimit)),
+		Offset:  int64(cmd.Uint(FlagEventsOffset)),
+	})
+	if err != nil {
+		return fmt.Errorf("listing events: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(cmd.Root().Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tTIME\tVERIFICATION\tPARTICIPANTS")
+	for _, e := range events {
+		swinces, err := db.ListEventSwinces(ctx, e.EventID)
+		if err != nil {
+			return fmt.Errorf("listing swinces of event %s: %w", e.EventID, err)
+		}
+		participants := make([]string, 0, len(swinces))
+		for _, s := range swinces {
+			participants = append(participants, strconv.FormatUint(s.ParticipantID, 10))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			e.EventID,
+			e.Time.In(guild.Location()).Format(time.DateTime),
+			e.Verification,
+			strings.Join(participants, ", "),
+		)
+	}
+	return tw.Flush()
+}
+
+func eventsShowAction(ctx context.Context, cmd *cli.Command) error {
+	eventID, err := eventArg(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	event, guild, err := getEvent(ctx, db, eventID)
+	if err != nil {
+		return err
+	}
+	swinces, err := db.ListEventSwinces(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("listing swinces: %w", err)
+	}
+	votes, err := db.CountVotes(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("counting votes: %w", err)
+	}
+
+	out := cmd.Root().Writer
+	fmt.Fprintf(out, "Event:        %s\n", event.EventID)
+	fmt.Fprintf(out, "Guild:        %d\n", event.GuildID)
+	fmt.Fprintf(out, "Time:         %s\n", event.Time.In(guild.Location()).Format(time.DateTime+" MST"))
+	fmt.Fprintf(out, "Verification: %s (%d approval(s), %d dispute(s))\n", event.Verification, votes.Approvals, votes.Disputes)
+	if event.Proof.Valid {
+		fmt.Fprintf(out, "Proof:        https://discord.com/channels/%d/%d/%d\n", guild.GuildID, guild.ChannelID, event.Proof.Int64)
+	}
+
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SWINCE\tPARTICIPANT\tNOMINEE\tFULFILLED BY")
+	for _, s := range swinces {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", s.SwinceID, s.ParticipantID, optionalID(s.NomineeID), orDash(s.FulfillmentID))
+	}
+	return tw.Flush()
+}
+
+func eventsDeleteAction(ctx context.Context, cmd *cli.Command) error {
+	eventID, err := eventArg(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	q := db.WithTx(tx)
+
+	// Nominations fulfilled during this event become open again
+	if err := q.UnlinkEventFulfillments(ctx, eventID); err != nil {
+		return fmt.Errorf("unlinking fulfillments: %w", err)
+	}
+	n, err := q.DeleteEvent(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("deleting event: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no event with ID %s", eventID)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Event deleted", "event_id", eventID)
+	return nil
+}
+
+func orDash(s sql.NullString) string {
+	if !s.Valid {
+		return "-"
+	}
+	return s.String
+}
@@ -1,14 +1,14 @@
 package app
 
 import (
-	"context"
+	"cmp"
+	"errors"
 	"fmt"
-	"log/slog"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
 	"github.com/urfave/cli/v3"
 )
 
@@ -19,121 +19,406 @@ const (
 	FlagLogFormat           = "log-format"
 	FlagLogLevel            = "log-level"
 	FlagLogOutput           = "log-output"
+	FlagLogMaxSize          = "log-max-size"
+	FlagLogRotateInterval   = "log-rotate-interval"
+	FlagLogMaxBackups       = "log-max-backups"
+	FlagLogMaxAge           = "log-max-age"
+	FlagLogCompress         = "log-compress"
+	FlagReportChannel       = "error-report-channel-id"
+	FlagReportUser          = "error-report-user-id"
+	FlagReportInterval      = "error-report-interval"
 	FlagSecretsPath         = "secrets-path"
+	FlagSecretsBackend      = "secrets-backend"
+	FlagSecretsFile         = "secrets-file"
+	FlagSecretsKeyFile      = "secrets-key-file"
+	FlagSecretsPassphrase   = "secrets-passphrase"
+	FlagSecretsEnvPrefix    = "secrets-env-prefix"
+	FlagSecretsPoll         = "secrets-poll-interval"
 	FlagDBCacheSize         = "database-cache-size"
 	FlagDiscordServer       = "discord-server-id"
 	FlagDiscordChannel      = "discord-channel-id"
 	FlagConversationTimeout = "discord-conversation-timeout"
+	FlagDiscordRetryMin     = "discord-retry-min"
+	FlagDiscordRetryMax     = "discord-retry-max"
+	FlagProofMaxSize        = "proof-max-size"
+	FlagProofArchive        = "proof-archive"
+	FlagDiscordAdmins       = "discord-admins"
+	FlagDiscordOperators    = "discord-operators"
+	FlagVerifyQuorum        = "verification-quorum"
+	FlagVerifyWindow        = "verification-dispute-window"
+	FlagNominationDeadline  = "nomination-deadline"
+	FlagNominationReminder  = "nomination-reminder"
+	FlagListenPort          = "listen-port"
+	FlagTokenTTL            = "api-token-ttl"
+	FlagKeyRotation         = "api-key-rotation"
+	FlagAnonymousReads      = "api-anonymous-reads"
+	FlagConfig              = "config"
+	FlagMetrics             = "metrics"
+	FlagTraceExporter       = "trace-exporter"
+	FlagTraceEndpoint       = "trace-otlp-endpoint"
+	FlagTraceFile           = "trace-file"
 )
 
 func flags() []cli.Flag {
 	return []cli.Flag{
+		// Config {{{
+		&cli.StringFlag{
+			Name:      FlagConfig,
+			Usage:     "YAML file holding default values for any of these flags (flags and env vars take precedence)",
+			Sources:   cli.EnvVars("CONFIG_FILE"),
+			TakesFile: true,
+		}, // }}}
 		// Discord {{{
 		&cli.UintFlag{
-			Name:     FlagDiscordServer,
-			Usage:    "Server the bot is involved int (1 bot per discord server)",
-			Sources:  cli.EnvVars("DISCORD_GUILD_ID"),
-			Required: true,
+			Name:    FlagDiscordServer,
+			Usage:   "Server configured at startup if it wasn't set up yet (prefer /setup)",
+			Sources: cli.EnvVars("DISCORD_GUILD_ID"),
+		},
+		&cli.UintFlag{
+			Name:    FlagDiscordChannel,
+			Usage:   "Channel where official bot communications occur on that server",
+			Sources: cli.EnvVars("DISCORD_CHANNEL_ID"),
+		},
+		&cli.UintSliceFlag{
+			Name:    FlagDiscordAdmins,
+			Usage:   "Users allowed to run administrative commands on that server",
+			Sources: cli.EnvVars("DISCORD_ADMINS"),
+		},
+		&cli.UintSliceFlag{
+			Name:    FlagDiscordOperators,
+			Usage:   "Users running the bot itself, allowed to change its log level from any server",
+			Sources: cli.EnvVars("DISCORD_OPERATORS"),
+		},
+		&cli.DurationFlag{
+			Name:             FlagConversationTimeout,
+			Usage:            "How long before an active DM conversation gets cancelled due to inactivity",
+			Sources:          cli.EnvVars("DISCORD_CONVERSATION_TIMEOUT"),
+			Value:            15 * time.Minute,
+			Validator:        atLeast(FlagConversationTimeout, time.Second),
+			ValidateDefaults: true,
+		},
+		&cli.DurationFlag{
+			Name:             FlagDiscordRetryMin,
+			Usage:            "Delay before retrying a failed connection to Discord, doubled after every failure",
+			Sources:          cli.EnvVars("DISCORD_RETRY_MIN"),
+			Value:            time.Second,
+			Validator:        atLeast(FlagDiscordRetryMin, 100*time.Millisecond),
+			ValidateDefaults: true,
+		},
+		&cli.DurationFlag{
+			Name:             FlagDiscordRetryMax,
+			Usage:            "Longest delay between connection attempts to Discord",
+			Sources:          cli.EnvVars("DISCORD_RETRY_MAX"),
+			Value:            2 * time.Minute,
+			Validator:        atLeast(FlagDiscordRetryMax, time.Second),
+			ValidateDefaults: true,
+		}, // }}}
+		// Proofs {{{
+		&cli.UintFlag{
+			Name:             FlagProofMaxSize,
+			Usage:            "Largest video accepted as proof of a swince (MB)",
+			Value:            25,
+			Sources:          cli.EnvVars("PROOF_MAX_SIZE"),
+			Validator:        atLeast(FlagProofMaxSize, uint64(1)),
+			ValidateDefaults: true,
 		},
+		&cli.StringFlag{
+			Name:    FlagProofArchive,
+			Usage:   "Directory where proofs get archived (archiving is disabled when empty)",
+			Sources: cli.EnvVars("PROOF_ARCHIVE_PATH"),
+		}, // }}}
+		// Verification {{{
 		&cli.UintFlag{
-			Name:     FlagDiscordChannel,
-			Usage:    "Channel where official bot communications occur",
-			Sources:  cli.EnvVars("DISCORD_CHANNEL_ID"),
-			Required: true,
+			Name:             FlagVerifyQuorum,
+			Usage:            "Peer approvals needed before a swince counts toward scores",
+			Value:            2,
+			Sources:          cli.EnvVars("VERIFICATION_QUORUM"),
+			Validator:        atLeast(FlagVerifyQuorum, uint64(1)),
+			ValidateDefaults: true,
+		},
+		&cli.DurationFlag{
+			Name:             FlagVerifyWindow,
+			Usage:            "Delay after which an undisputed swince counts even without reaching the quorum",
+			Value:            48 * time.Hour,
+			Sources:          cli.EnvVars("VERIFICATION_DISPUTE_WINDOW"),
+			Validator:        atLeast(FlagVerifyWindow, time.Minute),
+			ValidateDefaults: true,
+		}, // }}}
+		// Nominations {{{
+		&cli.DurationFlag{
+			Name:             FlagNominationDeadline,
+			Usage:            "Time a nominee has to answer a nomination",
+			Value:            24 * time.Hour,
+			Sources:          cli.EnvVars("NOMINATION_DEADLINE"),
+			Validator:        atLeast(FlagNominationDeadline, time.Minute),
+			ValidateDefaults: true,
 		},
 		&cli.DurationFlag{
-			Name:    FlagConversationTimeout,
-			Usage:   "How long before an active DM conversation gets cancelled due to inactivity",
-			Sources: cli.EnvVars("DISCORD_CONVERSATION_TIMEOUT"),
-			Value:   15 * time.Minute,
+			Name:    FlagNominationReminder,
+			Usage:   "How long before a nomination's deadline the nominee gets reminded (0 to never remind)",
+			Value:   6 * time.Hour,
+			Sources: cli.EnvVars("NOMINATION_REMINDER"),
+		}, // }}}
+		// API {{{
+		&cli.DurationFlag{
+			Name:             FlagTokenTTL,
+			Usage:            "Longest lifetime of the API tokens minted with /token",
+			Value:            90 * 24 * time.Hour,
+			Sources:          cli.EnvVars("API_TOKEN_TTL"),
+			Validator:        atLeast(FlagTokenTTL, time.Hour),
+			ValidateDefaults: true,
+		},
+		&cli.DurationFlag{
+			Name:             FlagKeyRotation,
+			Usage:            "How often a new key is generated to sign API tokens",
+			Value:            30 * 24 * time.Hour,
+			Sources:          cli.EnvVars("API_KEY_ROTATION"),
+			Validator:        atLeast(FlagKeyRotation, time.Hour),
+			ValidateDefaults: true,
+		},
+		&cli.BoolFlag{
+			Name:    FlagAnonymousReads,
+			Usage:   "Serve scores, seasons and events to requests without an API token",
+			Sources: cli.EnvVars("API_ANONYMOUS_READS"),
 		}, // }}}
 		// Logging {{{
 		&cli.StringFlag{
-			Name:    FlagLogFormat,
-			Usage:   "plain, json, none",
-			Value:   "plain",
-			Sources: cli.EnvVars("LOG_FORMAT"),
-			Action:  validateLogFormat,
+			Name:             FlagLogFormat,
+			Usage:            "plain, json, none",
+			Value:            "plain",
+			Sources:          cli.EnvVars("LOG_FORMAT"),
+			Validator:        validateLogFormat,
+			ValidateDefaults: true,
+		},
+		&cli.StringSliceFlag{
+			Name:             FlagLogOutput,
+			Usage:            "stdout, stderr, journald, syslog, syslog[+tcp]://host:port or a file, optionally prefixed by its format (ex: json:/var/log/swincebot.log)",
+			Value:            []string{"stdout"},
+			Sources:          cli.EnvVars("LOG_OUTPUT"),
+			Validator:        validateLogOutputs,
+			ValidateDefaults: true,
+		},
+		&cli.UintFlag{
+			Name:             FlagLogMaxSize,
+			Usage:            "Size (in MB) at which log files get rotated",
+			Value:            100,
+			Sources:          cli.EnvVars("LOG_MAX_SIZE"),
+			Validator:        atLeast(FlagLogMaxSize, uint64(1)),
+			ValidateDefaults: true,
+		},
+		&cli.DurationFlag{
+			Name:    FlagLogRotateInterval,
+			Usage:   "Also rotate log files this often (0 only rotates them by size)",
+			Sources: cli.EnvVars("LOG_ROTATE_INTERVAL"),
+		},
+		&cli.UintFlag{
+			Name:    FlagLogMaxBackups,
+			Usage:   "Rotated log files to keep (0 keeps them all)",
+			Value:   7,
+			Sources: cli.EnvVars("LOG_MAX_BACKUPS"),
+		},
+		&cli.DurationFlag{
+			Name:    FlagLogMaxAge,
+			Usage:   "Delete rotated log files older than this, rounded up to days (0 keeps them)",
+			Sources: cli.EnvVars("LOG_MAX_AGE"),
+		},
+		&cli.BoolFlag{
+			Name:    FlagLogCompress,
+			Usage:   "Compress rotated log files with gzip",
+			Value:   true,
+			Sources: cli.EnvVars("LOG_COMPRESS"),
 		},
 		&cli.StringFlag{
-			Name:    FlagLogOutput,
-			Usage:   "stdout, stderr, file",
-			Value:   "stdout",
-			Sources: cli.EnvVars("LOG_OUTPUT"),
-			Action:  validateLogOutput,
+			Name:             FlagLogLevel,
+			Usage:            "debug, info, warn, error",
+			Value:            "info",
+			Sources:          cli.EnvVars("LOG_LEVEL"),
+			Validator:        validateLogLevel,
+			ValidateDefaults: true,
+		}, // }}}
+		// Error reports {{{
+		&cli.UintFlag{
+			Name:    FlagReportChannel,
+			Usage:   "Channel where logged errors get reported (reports are off unless this or a user is set)",
+			Sources: cli.EnvVars("ERROR_REPORT_CHANNEL_ID"),
+		},
+		&cli.UintFlag{
+			Name:    FlagReportUser,
+			Usage:   "User to DM logged errors to when no report channel is set",
+			Sources: cli.EnvVars("ERROR_REPORT_USER_ID"),
+		},
+		&cli.DurationFlag{
+			Name:             FlagReportInterval,
+			Usage:            "Repeats of an error are reported at most this often, as a single summary",
+			Value:            15 * time.Minute,
+			Sources:          cli.EnvVars("ERROR_REPORT_INTERVAL"),
+			Validator:        atLeast(FlagReportInterval, time.Minute),
+			ValidateDefaults: true,
+		}, // }}}
+		// Tracing {{{
+		&cli.StringFlag{
+			Name:             FlagTraceExporter,
+			Usage:            "Where spans get exported: none, otlp, file (trace IDs show up in logs either way)",
+			Value:            "none",
+			Sources:          cli.EnvVars("TRACE_EXPORTER"),
+			Validator:        validateTraceExporter,
+			ValidateDefaults: true,
+		},
+		&cli.StringFlag{
+			Name:    FlagTraceEndpoint,
+			Usage:   "OTLP/HTTP traces endpoint of the collector used by the otlp exporter",
+			Value:   "http://localhost:4318/v1/traces",
+			Sources: cli.EnvVars("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
 		},
 		&cli.StringFlag{
-			Name:    FlagLogLevel,
-			Usage:   "debug, info, warn, error",
-			Value:   "info",
-			Sources: cli.EnvVars("LOG_LEVEL"),
-			Action:  validateLogLevel,
+			Name:      FlagTraceFile,
+			Usage:     "File the file exporter appends spans to (one JSON object per line)",
+			Value:     "traces.jsonl",
+			Sources:   cli.EnvVars("TRACE_FILE"),
+			TakesFile: true,
 		}, // }}}
 		// Database {{{
 		&cli.UintFlag{
-			Name:    FlagDBCacheSize,
-			Value:   16000,
-			Usage:   "Database cache to keep in memory (MB)",
-			Sources: cli.EnvVars("DATABASE_CACHE_SIZE"),
+			Name:             FlagDBCacheSize,
+			Value:            16000,
+			Usage:            "Database cache to keep in memory (MB)",
+			Sources:          cli.EnvVars("DATABASE_CACHE_SIZE"),
+			Validator:        atLeast(FlagDBCacheSize, uint64(1)),
+			ValidateDefaults: true,
 		},
 		&cli.StringFlag{
-			Name:    FlagDBPath,
-			Value:   "store.db",
-			Usage:   "database file",
-			Sources: cli.EnvVars("DATABASE_PATH"),
+			Name:             FlagDBPath,
+			Value:            "store.db",
+			Usage:            "database file",
+			Sources:          cli.EnvVars("DATABASE_PATH"),
+			Validator:        notEmpty(FlagDBPath),
+			ValidateDefaults: true,
 		}, // }}}
 		// Service {{{
+		&cli.UintFlag{
+			Name:             FlagListenPort,
+			Usage:            "Port of the read-only HTTP API",
+			Value:            1157,
+			Sources:          cli.EnvVars("LISTEN_PORT"),
+			Validator:        validatePort,
+			ValidateDefaults: true,
+		},
 		&cli.DurationFlag{
-			Name:    FlagGraceTimeout,
-			Usage:   "Maximum time given to terminate active connections before being force-killed",
-			Value:   3 * time.Second,
-			Sources: cli.EnvVars("GRACEFUL_TIMEOUT"),
+			Name:             FlagGraceTimeout,
+			Usage:            "Maximum time given to terminate active connections before being force-killed",
+			Value:            3 * time.Second,
+			Sources:          cli.EnvVars("GRACEFUL_TIMEOUT"),
+			Validator:        atLeast(FlagGraceTimeout, time.Duration(0)),
+			ValidateDefaults: true,
+		},
+		&cli.BoolFlag{
+			Name:    FlagMetrics,
+			Usage:   "Serve Prometheus metrics on /metrics (no API token needed)",
+			Sources: cli.EnvVars("METRICS_ENABLED"),
+		}, // }}}
+		// Secrets {{{
+		&cli.StringSliceFlag{
+			Name:             FlagSecretsBackend,
+			Usage:            "Where secrets are kept: dir, env, file (several are tried in the given order)",
+			Value:            []string{"dir"},
+			Sources:          cli.EnvVars("SECRETS_BACKEND"),
+			Validator:        validateSecretsBackend,
+			ValidateDefaults: true,
 		},
 		&cli.StringFlag{
 			Name:    FlagSecretsPath,
 			Usage:   "Directory containing necessary secrets (tokens, etc...)",
 			Value:   "/etc/secrets",
 			Sources: cli.EnvVars("SECRETS_PATH"),
+		},
+		&cli.StringFlag{
+			Name:    FlagSecretsFile,
+			Usage:   "Encrypted vault used by the file backend",
+			Value:   "secrets.vault",
+			Sources: cli.EnvVars("SECRETS_FILE"),
+		},
+		&cli.StringFlag{
+			Name:    FlagSecretsKeyFile,
+			Usage:   "File holding the passphrase of the encrypted vault",
+			Sources: cli.EnvVars("SECRETS_KEY_FILE"),
+		},
+		&cli.StringFlag{
+			Name:    FlagSecretsPassphrase,
+			Usage:   "Passphrase of the encrypted vault (prefer the key file)",
+			Sources: cli.EnvVars("SECRETS_PASSPHRASE"),
+		},
+		&cli.StringFlag{
+			Name:    FlagSecretsEnvPrefix,
+			Usage:   "Prefix of the variables read by the env backend",
+			Value:   "SWINCEBOT_",
+			Sources: cli.EnvVars("SECRETS_ENV_PREFIX"),
+		},
+		&cli.DurationFlag{
+			Name:             FlagSecretsPoll,
+			Usage:            "How often the secrets directory is checked for rotated secrets (0 disables it)",
+			Value:            30 * time.Second,
+			Sources:          cli.EnvVars("SECRETS_POLL_INTERVAL"),
+			Validator:        atLeast(FlagSecretsPoll, time.Duration(0)),
+			ValidateDefaults: true,
 		}, // }}}
 	}
 }
 
-func validateLogOutput(ctx context.Context, cmd *cli.Command, s string) error {
-	switch s {
-	case "stdout", "stderr":
+// atLeast rejects values below min
+func atLeast[T cmp.Ordered](name string, min T) func(T) error {
+	return func(v T) error {
+		if v < min {
+			return fmt.Errorf("%s must be at least %v (got %v)", name, min, v)
+		}
 		return nil
-	default:
-		// assume file
-		f, err := os.OpenFile(s, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			slog.ErrorContext(
-				ctx,
-				fmt.Sprintf("Error creating/accessing provided log file %s", s),
-			)
-			return err
+	}
+}
+
+func notEmpty(name string) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			return fmt.Errorf("%s can't be empty", name)
 		}
-		defer f.Close() //nolint:errcheck
 		return nil
 	}
 }
 
-func validateLogLevel(ctx context.Context, cmd *cli.Command, s string) error {
+func validatePort(port uint64) error {
+	if port < 1024 || port > 65535 {
+		return fmt.Errorf("%w: %d", util.ErrOutOfBoundsPort, port)
+	}
+	return nil
+}
+
+func validateLogOutputs(outputs []string) error {
+	var errs []error
+	for _, spec := range outputs {
+		errs = append(errs, logging.ValidateOutput(spec))
+	}
+	return errors.Join(errs...)
+}
+
+func validateLogLevel(s string) error {
 	for _, lvl := range []string{"deb", "inf", "warn", "err"} {
 		if strings.Contains(strings.ToLower(s), lvl) {
 			return nil
 		}
 	}
-	slog.ErrorContext(
-		ctx,
-		fmt.Sprintf("Unknown log level provided: %s", s),
-	)
-	return logging.ErrInvalidLevel
+	return fmt.Errorf("%w: %s", logging.ErrInvalidLevel, s)
 }
 
-func validateLogFormat(ctx context.Context, cmd *cli.Command, s string) error {
-	s = strings.ToLower(s)
-	if s == "json" || s == "plain" || s == "none" {
+func validateLogFormat(s string) error {
+	switch strings.ToLower(s) {
+	case "json", "plain", "none":
 		return nil
 	}
-	return nil
+	return fmt.Errorf("%w: %s", logging.ErrInvalidFormat, s)
+}
+
+func validateTraceExporter(s string) error {
+	switch s {
+	case "none", "otlp", "file":
+		return nil
+	}
+	return fmt.Errorf("unknown trace exporter %q (expected none, otlp or file)", s)
 }
@@ -0,0 +1,231 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagGuildChannel = "channel"
+	FlagGuildAdmins  = "admins"
+	FlagGuildRuleset = "ruleset"
+
+	FlagGuildAnnouncements = "announcements"
+	FlagGuildAdminRole     = "admin-role"
+	FlagGuildTimezone      = "timezone"
+)
+
+func guildsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "guilds",
+		Usage: "Manage the Discord servers served by the bot (changes apply on the next start)",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List configured servers",
+				Action: guildsListAction,
+			},
+			{
+				Name:      "add",
+				Usage:     "Configure a server (or update an existing one)",
+				ArgsUsage: "<guild-id>",
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:     FlagGuildChannel,
+						Usage:    "Channel where swinces get reposted for verification",
+						Required: true,
+					},
+					&cli.UintFlag{
+						Name:  FlagGuildAnnouncements,
+						Usage: "Channel where approved swinces get announced",
+					},
+					&cli.UintSliceFlag{
+						Name:  FlagGuildAdmins,
+						Usage: "Users allowed to run administrative commands on that server",
+					},
+					&cli.UintFlag{
+						Name:  FlagGuildAdminRole,
+						Usage: "Role allowed to run administrative commands on that server",
+					},
+					&cli.StringFlag{
+						Name:  FlagGuildTimezone,
+						Usage: "IANA timezone used to display dates (ex: America/Toronto)",
+						Value: "UTC",
+						Action: func(ctx context.Context, cmd *cli.Command, s string) error {
+							_, err := time.LoadLocation(s)
+							return err
+						},
+					},
+					&cli.StringFlag{
+						Name:  FlagGuildRuleset,
+						Usage: "Name of a builtin ruleset (ex: v0) or path to a JSON ruleset file",
+						Value: ruleset.Default,
+					},
+				},
+				Action: guildsAddAction,
+			},
+			{
+				Name:      "remove",
+				Usage:     "Forget a server along with its whole swince history",
+				ArgsUsage: "<guild-id>",
+				Action:    guildsRemoveAction,
+			},
+		},
+	}
+}
+
+// commandGuild is the server offline commands work on
+func commandGuild(cmd *cli.Command) (uint64, error) {
+	if !cmd.IsSet(FlagDiscordServer) {
+		return 0, fmt.Errorf("--%s is required to pick the server to work on", FlagDiscordServer)
+	}
+	return cmd.Uint(FlagDiscordServer), nil
+}
+
+// guildArg parses the guild ID given as the only argument of a subcommand
+func guildArg(cmd *cli.Command) (uint64, error) {
+	if cmd.Args().Len() != 1 {
+		return 0, errors.New("expected exactly one guild ID")
+	}
+	id, err := strconv.ParseUint(cmd.Args().First(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid guild ID %q: %w", cmd.Args().First(), err)
+	}
+	return id, nil
+}
+
+func guildsListAction(ctx context.Context, cmd *cli.Command) error {
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	guilds, err := db.ListGuilds(ctx)
+	if err != nil {
+		return fmt.Errorf("listing guilds: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(cmd.Root().Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GUILD\tCHANNEL\tANNOUNCEMENTS\tRULESET\tTIMEZONE\tADMIN ROLE\tADMINS")
+	for _, g := range guilds {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			g.GuildID, g.ChannelID, optionalID(g.AnnouncementChannelID),
+			g.Ruleset, g.Timezone, optionalID(g.AdminRoleID), g.Admins,
+		)
+	}
+	return tw.Flush()
+}
+
+func guildsAddAction(ctx context.Context, cmd *cli.Command) error {
+	guildID, err := guildArg(cmd)
+	if err != nil {
+		return err
+	}
+	if _, err := ruleset.Load(cmd.String(FlagGuildRuleset), 0); err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	guild := database.UpsertGuildParams{
+		GuildID:   guildID,
+		ChannelID: cmd.Uint(FlagGuildChannel),
+		Admins:    database.FormatAdmins(cmd.UintSlice(FlagGuildAdmins)),
+		Timezone:  cmd.String(FlagGuildTimezone),
+		Ruleset:   cmd.String(FlagGuildRuleset),
+	}
+	if cmd.IsSet(FlagGuildAnnouncements) {
+		id := cmd.Uint(FlagGuildAnnouncements)
+		guild.AnnouncementChannelID = &id
+	}
+	if cmd.IsSet(FlagGuildAdminRole) {
+		id := cmd.Uint(FlagGuildAdminRole)
+		guild.AdminRoleID = &id
+	}
+
+	if err := db.UpsertGuild(ctx, guild); err != nil {
+		return fmt.Errorf("saving guild: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Guild configured", "guild_id", guildID)
+	return nil
+}
+
+func guildsRemoveAction(ctx context.Context, cmd *cli.Command) error {
+	guildID, err := guildArg(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	n, err := db.DeleteGuild(ctx, guildID)
+	if err != nil {
+		return fmt.Errorf("removing guild: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("guild %d isn't configured", guildID)
+	}
+
+	slog.InfoContext(ctx, "Guild removed", "guild_id", guildID)
+	return nil
+}
+
+func optionalID(id *uint64) string {
+	if id == nil {
+		return "-"
+	}
+	return strconv.FormatUint(*id, 10)
+}
+
+// seedGuild configures the server given by the --discord-* flags unless it was
+// already set up (through /setup or a previous seed): the flags are only
+// defaults.
+func seedGuild(ctx context.Context, cmd *cli.Command, db *database.ProtoDB) error {
+	if !cmd.IsSet(FlagDiscordServer) {
+		return nil
+	}
+	if !cmd.IsSet(FlagDiscordChannel) {
+		return fmt.Errorf("--%s is required along with --%s", FlagDiscordChannel, FlagDiscordServer)
+	}
+
+	guild := database.UpsertGuildParams{
+		GuildID:   cmd.Uint(FlagDiscordServer),
+		ChannelID: cmd.Uint(FlagDiscordChannel),
+		Admins:    database.FormatAdmins(cmd.UintSlice(FlagDiscordAdmins)),
+		Timezone:  "UTC",
+		Ruleset:   ruleset.Default,
+	}
+	_, err := db.GetGuild(ctx, guild.GuildID)
+	switch {
+	case err == nil:
+		slog.DebugContext(ctx, "Guild already configured, ignoring seed flags", "guild_id", guild.GuildID)
+		return nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("getting guild: %w", err)
+	}
+
+	if err := db.UpsertGuild(ctx, guild); err != nil {
+		return fmt.Errorf("saving guild: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	healthcheckName = "healthcheck"
+
+	FlagHealthTimeout = "timeout"
+	FlagHealthLive    = "live"
+)
+
+// healthcheckCommand lets container runtimes probe the bot without curl (the
+// image is built from scratch). The config file isn't read: set the listen
+// port with its flag or environment variable.
+func healthcheckCommand() *cli.Command {
+	return &cli.Command{
+		Name:  healthcheckName,
+		Usage: "Probe the health endpoints of the bot running on this host, failing when it isn't ready",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  FlagHealthTimeout,
+				Usage: "Time given to each endpoint to answer",
+				Value: 5 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:  FlagHealthLive,
+				Usage: "Only check that the process is alive (skips /readyz)",
+			},
+		},
+		Action: healthcheckAction,
+	}
+}
+
+func healthcheckAction(ctx context.Context, cmd *cli.Command) error {
+	client := &http.Client{Timeout: cmd.Duration(FlagHealthTimeout)}
+	base := fmt.Sprintf("http://127.0.0.1:%d", cmd.Uint(FlagListenPort))
+
+	endpoints := []string{"/healthz", "/readyz"}
+	if cmd.Bool(FlagHealthLive) {
+		endpoints = endpoints[:1]
+	}
+	for _, path := range endpoints {
+		body, err := probe(ctx, client, base+path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(cmd.Root().Writer, "%s: %s\n", path, body)
+	}
+	return nil
+}
+
+// probe fetches url, failing on anything but a 200
+func probe(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("reading response: %w", err)
+	}
+	text := strings.TrimSpace(string(body))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return text, nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+)
+
+// nominationBuckets split open nominations by the time left to answer them
+var nominationBuckets = []struct {
+	label string
+	upTo  time.Duration
+}{
+	{"under_1h", time.Hour},
+	{"under_6h", 6 * time.Hour},
+	{"under_24h", 24 * time.Hour},
+	{"24h_or_more", math.MaxInt64},
+}
+
+// watchNominations exposes the open nominations of every guild, counted when
+// metrics get scraped
+func watchNominations(db *database.ProtoDB, deadline time.Duration) {
+	metrics.NewGaugeFunc(
+		"swincebot_open_nominations",
+		"Nominations waiting for an answer, by time left before their deadline",
+		func(ctx context.Context) ([]metrics.Sample, error) {
+			now := time.Now()
+			guilds, err := db.ListGuilds(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("listing guilds: %w", err)
+			}
+			overdue, err := db.CountOverdueNominations(ctx, now.Add(-deadline))
+			if err != nil {
+				return nil, fmt.Errorf("counting overdue nominations: %w", err)
+			}
+			open, err := db.ListOpenNominations(ctx, now.Add(-deadline))
+			if err != nil {
+				return nil, fmt.Errorf("listing open nominations: %w", err)
+			}
+
+			counts := make(map[uint64][]float64, len(guilds))
+			for _, g := range guilds {
+				counts[g.GuildID] = make([]float64, len(nominationBuckets)+1) // overdue first
+			}
+			for _, o := range overdue {
+				if c, ok := counts[o.GuildID]; ok {
+					c[0] = float64(o.Nominations)
+				}
+			}
+			for _, n := range open {
+				c, ok := counts[n.GuildID]
+				if !ok {
+					continue
+				}
+				left := n.Time.Add(deadline).Sub(now)
+				for i, b := range nominationBuckets {
+					if left < b.upTo {
+						c[i+1]++
+						break
+					}
+				}
+			}
+
+			samples := make([]metrics.Sample, 0, len(guilds)*(len(nominationBuckets)+1))
+			for _, g := range guilds {
+				guild := strconv.FormatUint(g.GuildID, 10)
+				c := counts[g.GuildID]
+				samples = append(samples, metrics.Sample{Labels: []string{guild, "overdue"}, Value: c[0]})
+				for i, b := range nominationBuckets {
+					samples = append(samples, metrics.Sample{Labels: []string{guild, b.label}, Value: c[i+1]})
+				}
+			}
+			return samples, nil
+		},
+		"guild", "remaining",
+	)
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/urfave/cli/v3"
+)
+
+const FlagScoreSeason = "season"
+
+func scoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "score",
+		Usage:     "Show a user's score for a season and of all time",
+		ArgsUsage: "<user-id>",
+		Flags: []cli.Flag{
+			&cli.UintFlag{
+				Name:  FlagScoreSeason,
+				Usage: "Season to score (defaults to the current season)",
+			},
+		},
+		Action: scoreAction,
+	}
+}
+
+func scoreAction(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return errors.New("expected exactly one user ID")
+	}
+	userID, err := strconv.ParseUint(cmd.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid user ID %q: %w", cmd.Args().First(), err)
+	}
+	guildID, err := commandGuild(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	if _, err := getGuild(ctx, db, guildID); err != nil {
+		return err
+	}
+	ruleset.InitializeRulesets(cmd.Duration(FlagVerifyWindow))
+
+	now := time.Now()
+	season := int(cmd.Uint(FlagScoreSeason))
+	if !cmd.IsSet(FlagScoreSeason) {
+		if season, err = ruleset.CurrentSeason(ctx, db, guildID, now); err != nil {
+			return err
+		}
+	}
+
+	seasonScores, err := ruleset.SeasonScores(ctx, db, guildID, season, now)
+	if err != nil {
+		return err
+	}
+	allTimeScores, err := ruleset.AllTimeScores(ctx, db, guildID, now)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(cmd.Root().Writer, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\tRANK\tSWINCES\tNOMINATIONS\tFULFILLMENTS\tTOTAL\t")
+	for _, row := range []struct {
+		label  string
+		scores ruleset.Scores
+	}{
+		{fmt.Sprintf("Season %d", season), seasonScores},
+		{"All time", allTimeScores},
+	} {
+		b := row.scores[userID]
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t\n",
+			row.label, userRank(row.scores, userID), b.Swinces, b.Nominations, b.Fulfillments, b.Total,
+		)
+	}
+	return tw.Flush()
+}
+
+// userRank is the user's position on the leaderboard, if they're on it
+func userRank(scores ruleset.Scores, userID uint64) string {
+	for _, entry := range scores.Leaderboard(0) {
+		if entry.User.ID == userID {
+			return strconv.Itoa(entry.Rank)
+		}
+	}
+	return "-"
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagSeasonStart   = "start"
+	FlagSeasonRuleset = "ruleset"
+)
+
+// seasonLayouts are accepted as season start, in the server's timezone unless
+// an offset is given
+var seasonLayouts = []string{
+	time.RFC3339,
+	time.DateTime,
+	time.DateOnly,
+}
+
+func seasonsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "seasons",
+		Usage: "Manage the server's seasons",
+		Commands: []*cli.Command{
+			{
+				Name:  "add",
+				Usage: "Start a new season",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  FlagSeasonStart,
+						Usage: "When the season starts (ex: 2025-09-01, 2025-09-01 18:00:00, RFC3339), defaults to now",
+					},
+					&cli.StringFlag{
+						Name:     FlagSeasonRuleset,
+						Usage:    "Name of a builtin ruleset (ex: v0) or path to a JSON ruleset file",
+						Required: true,
+					},
+				},
+				Action: seasonsAddAction,
+			},
+		},
+	}
+}
+
+func parseSeasonStart(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range seasonLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized season start %q", s)
+}
+
+func seasonsAddAction(ctx context.Context, cmd *cli.Command) error {
+	guildID, err := commandGuild(cmd)
+	if err != nil {
+		return err
+	}
+	name := cmd.String(FlagSeasonRuleset)
+	if _, err := ruleset.Load(name, cmd.Duration(FlagVerifyWindow)); err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	guild, err := getGuild(ctx, db, guildID)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now().UTC()
+	if cmd.IsSet(FlagSeasonStart) {
+		if start, err = parseSeasonStart(cmd.String(FlagSeasonStart), guild.Location()); err != nil {
+			return err
+		}
+	}
+
+	err = db.CreateSeason(ctx, database.CreateSeasonParams{
+		GuildID:   guildID,
+		StartTime: start,
+		Ruleset:   name,
+	})
+	if err != nil {
+		return fmt.Errorf("creating season: %w", err)
+	}
+
+	season, err := ruleset.CurrentSeason(ctx, db, guildID, start)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "Season added", "guild_id", guildID, "season", season, "start", start, "ruleset", name)
+	return nil
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/urfave/cli/v3"
+)
+
+func validateSecretsBackend(backends []string) error {
+	for _, backend := range backends {
+		switch backend {
+		case "dir", "env", "file":
+		default:
+			return fmt.Errorf("unknown secrets backend: %s", backend)
+		}
+	}
+	return nil
+}
+
+// openVault opens the secrets vault described by the flags. Listing several
+// backends layers them: secrets are looked up in the given order.
+func openVault(cmd *cli.Command) (secrets.SecretVault, error) {
+	var layers []secrets.SecretVault
+	for _, backend := range cmd.StringSlice(FlagSecretsBackend) {
+		var (
+			vault secrets.SecretVault
+			err   error
+		)
+		switch backend {
+		case "dir":
+			vault, err = secrets.NewDirVault(cmd.String(FlagSecretsPath))
+		case "env":
+			vault = secrets.NewEnvVault(cmd.String(FlagSecretsEnvPrefix))
+		case "file":
+			vault, err = openFileVault(cmd)
+		default:
+			err = fmt.Errorf("unknown secrets backend: %s", backend)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("opening %s secrets backend: %w", backend, err)
+		}
+		layers = append(layers, vault)
+	}
+
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	return secrets.NewLayeredVault(layers...), nil
+}
+
+func openFileVault(cmd *cli.Command) (*secrets.FileVault, error) {
+	if keyFile := cmd.String(FlagSecretsKeyFile); keyFile != "" {
+		return secrets.NewFileVaultFromKeyFile(cmd.String(FlagSecretsFile), keyFile)
+	}
+	return secrets.NewFileVault(cmd.String(FlagSecretsFile), secrets.Secret(cmd.String(FlagSecretsPassphrase)))
+}
+
+func secretsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "Manage the secrets vault",
+		Commands: []*cli.Command{
+			{
+				Name:      "set",
+				Usage:     "Store a secret read from stdin (ex: discord_bot_token)",
+				ArgsUsage: "<key>",
+				Action:    secretsSetAction,
+			},
+		},
+	}
+}
+
+func secretsSetAction(ctx context.Context, cmd *cli.Command) error {
+	key := cmd.Args().First()
+	if key == "" || cmd.Args().Len() > 1 {
+		return errors.New("expected exactly one secret key")
+	}
+
+	val, err := io.ReadAll(cmd.Root().Reader)
+	if err != nil {
+		return fmt.Errorf("reading secret: %w", err)
+	}
+
+	vault, err := openVault(cmd)
+	if err != nil {
+		return err
+	}
+	if err := vault.Set(key, secrets.Secret(strings.TrimRight(string(val), "\r\n"))); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Secret stored", "key", key)
+	return nil
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagSimRuleset = "ruleset"
+	FlagSimSeason  = "season"
+	FlagSimFormat  = "format"
+)
+
+func simulateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "simulate",
+		Usage: "Re-score a season under a candidate ruleset and compare it with the actual leaderboard",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     FlagSimRuleset,
+				Usage:    "Name of a builtin ruleset (ex: v0) or path to a JSON ruleset file",
+				Required: true,
+			},
+			&cli.UintFlag{
+				Name:  FlagSimSeason,
+				Usage: "Season to re-score (defaults to the current season)",
+			},
+			&cli.StringFlag{
+				Name:   FlagSimFormat,
+				Usage:  "table, csv, json",
+				Value:  "table",
+				Action: validateOutputFormat,
+			},
+		},
+		Action: simulate,
+	}
+}
+
+func simulate(ctx context.Context, cmd *cli.Command) error {
+	guildID, err := commandGuild(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	disputeWindow := cmd.Duration(FlagVerifyWindow)
+	ruleset.InitializeRulesets(disputeWindow)
+
+	now := time.Now()
+	season := int(cmd.Uint(FlagSimSeason))
+	if !cmd.IsSet(FlagSimSeason) {
+		current, err := db.GetSeasonID(ctx, database.GetSeasonIDParams{
+			GuildID:   guildID,
+			StartTime: now,
+		})
+		if err != nil {
+			return fmt.Errorf("getting current season: %w", err)
+		}
+		season = int(current)
+	}
+
+	actual, err := ruleset.ForSeason(ctx, db, guildID, season)
+	if err != nil {
+		return err
+	}
+	candidate, err := ruleset.Load(cmd.String(FlagSimRuleset), disputeWindow)
+	if err != nil {
+		return err
+	}
+
+	ds, err := ruleset.LoadSeason(ctx, db, guildID, season, now)
+	if err != nil {
+		return err
+	}
+
+	comparisons := ruleset.Compare(
+		actual.Scores(ds, now).Leaderboard(0),
+		candidate.Scores(ds, now).Leaderboard(0),
+	)
+
+	out := cmd.Root().Writer
+	switch cmd.String(FlagSimFormat) {
+	case "csv":
+		return writeComparisonsCSV(out, comparisons)
+	case "json":
+		return writeComparisonsJSON(out, season, ds, comparisons)
+	default:
+		return writeComparisonsTable(out, comparisons)
+	}
+}
+
+func writeComparisonsTable(w io.Writer, comparisons []ruleset.Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "USER\tACTUAL RANK\tACTUAL PTS\tSIMULATED RANK\tSIMULATED PTS\tDELTA\t")
+	for _, c := range comparisons {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t\n",
+			c.UserID,
+			c.ActualRank, c.ActualScore,
+			c.SimulatedRank, c.SimulatedScore,
+			formatDelta(c.RankDelta),
+		)
+	}
+	return tw.Flush()
+}
+
+func writeComparisonsCSV(w io.Writer, comparisons []ruleset.Comparison) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"user_id", "actual_rank", "actual_score", "simulated_rank", "simulated_score", "rank_delta"})
+	for _, c := range comparisons {
+		_ = cw.Write([]string{
+			strconv.FormatUint(c.UserID, 10),
+			strconv.Itoa(c.ActualRank),
+			strconv.Itoa(c.ActualScore),
+			strconv.Itoa(c.SimulatedRank),
+			strconv.Itoa(c.SimulatedScore),
+			strconv.Itoa(c.RankDelta),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeComparisonsJSON(w io.Writer, season int, ds ruleset.Dataset, comparisons []ruleset.Comparison) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Season      int                  `json:"season"`
+		Start       time.Time            `json:"start"`
+		End         time.Time            `json:"end"`
+		Comparisons []ruleset.Comparison `json:"comparisons"`
+	}{season, ds.Start, ds.End, comparisons})
+}
+
+func formatDelta(delta int) string {
+	switch {
+	case delta > 0:
+		return fmt.Sprintf("+%d", delta)
+	case delta < 0:
+		return strconv.Itoa(delta)
+	default:
+		return "="
+	}
+}
+
+func validateOutputFormat(ctx context.Context, cmd *cli.Command, s string) error {
+	switch s {
+	case "table", "csv", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown output format: %s", s)
+}
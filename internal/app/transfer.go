@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/ChausseBenjamin/swincebot/internal/transfer"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	FlagTransferFormat = "format"
+	FlagTransferOutput = "output"
+	FlagTransferInput  = "input"
+	FlagTransferDryRun = "dry-run"
+)
+
+func transferFormatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  FlagTransferFormat,
+		Usage: "json (single versioned file), csv (one file per table in a directory)",
+		Value: "json",
+		Action: func(ctx context.Context, cmd *cli.Command, s string) error {
+			if s != "json" && s != "csv" {
+				return fmt.Errorf("unknown transfer format: %s", s)
+			}
+			return nil
+		},
+	}
+}
+
+func exportCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Dump events, swinces, seasons, archives and votes",
+		Flags: []cli.Flag{
+			transferFormatFlag(),
+			&cli.StringFlag{
+				Name:  FlagTransferOutput,
+				Usage: "File to write (json, - for stdout) or directory to fill (csv)",
+				Value: "-",
+			},
+		},
+		Action: exportAction,
+	}
+}
+
+func importCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "import",
+		Usage: "Load a dump created by the export command",
+		Flags: []cli.Flag{
+			transferFormatFlag(),
+			&cli.StringFlag{
+				Name:     FlagTransferInput,
+				Usage:    "File to read (json, - for stdin) or directory to read from (csv)",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  FlagTransferDryRun,
+				Usage: "Validate the dump and rehearse the import without persisting anything",
+			},
+		},
+		Action: importAction,
+	}
+}
+
+func exportAction(ctx context.Context, cmd *cli.Command) error {
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	d, err := transfer.Export(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	output := cmd.String(FlagTransferOutput)
+	if cmd.String(FlagTransferFormat) == "csv" {
+		if output == "-" {
+			return fmt.Errorf("csv exports need an output directory")
+		}
+		return transfer.WriteCSV(output, d)
+	}
+
+	if output == "-" {
+		return transfer.WriteJSON(cmd.Root().Writer, d)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	if err := transfer.WriteJSON(f, d); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func importAction(ctx context.Context, cmd *cli.Command) error {
+	input := cmd.String(FlagTransferInput)
+
+	var (
+		d   *transfer.Dump
+		err error
+	)
+	if cmd.String(FlagTransferFormat) == "csv" {
+		d, err = transfer.ReadCSV(input)
+	} else {
+		var r io.Reader = os.Stdin
+		if input != "-" {
+			f, openErr := os.Open(input)
+			if openErr != nil {
+				return openErr
+			}
+			defer f.Close() //nolint:errcheck
+			r = f
+		}
+		d, err = transfer.ReadJSON(r)
+	}
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer db.DB.Close() //nolint:errcheck
+
+	dryRun := cmd.Bool(FlagTransferDryRun)
+	if err := transfer.Import(ctx, db, d, dryRun); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Import complete",
+		"dry_run", dryRun,
+		"events", len(d.Events),
+		"swinces", len(d.Swinces),
+		"seasons", len(d.Seasons),
+		"archives", len(d.Archives),
+		"votes", len(d.Votes),
+	)
+	return nil
+}
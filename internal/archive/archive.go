@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrTooLarge = errors.New("archived file exceeds the maximum size")
+
+// Entry describes a file stored in the archive
+type Entry struct {
+	SHA256 string
+	Size   int64
+	Path   string
+}
+
+// Archiver keeps a local, content-addressed copy of swince proofs so they
+// outlive the (expiring) Discord CDN links.
+// Files are stored as <dir>/<first 2 hex chars>/<sha256>.
+type Archiver struct {
+	dir     string
+	maxSize int64
+}
+
+func New(dir string, maxSize int64) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("creating archive directory: %w", err)
+	}
+	return &Archiver{
+		dir:     dir,
+		maxSize: maxSize,
+	}, nil
+}
+
+// Store copies r into the archive while hashing it
+func (a *Archiver) Store(r io.Reader) (Entry, error) {
+	tmp, err := os.CreateTemp(a.dir, ".incoming-*")
+	if err != nil {
+		return Entry{}, fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, a.maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("writing archive file: %w", err)
+	}
+	if size > a.maxSize {
+		return Entry{}, ErrTooLarge
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	path := a.Path(sum)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return Entry{}, fmt.Errorf("creating archive subdirectory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Entry{}, fmt.Errorf("moving archive file into place: %w", err)
+	}
+
+	return Entry{SHA256: sum, Size: size, Path: path}, nil
+}
+
+// Path returns where content with the given hash is (or would be) stored
+func (a *Archiver) Path(sum string) string {
+	return filepath.Join(a.dir, sum[:2], sum)
+}
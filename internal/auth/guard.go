@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Public marks endpoints which don't require any token
+const Public Scope = ""
+
+// Guard enforces API tokens on HTTP requests and gRPC calls. Verified claims
+// are stored in the request context under util.ClaimsKey.
+type Guard struct {
+	issuer         *Issuer
+	anonymousReads bool
+}
+
+// NewGuard returns a guard verifying tokens with the issuer. With
+// anonymousReads, endpoints requiring ScopeRead also accept requests without
+// a token (requests presenting one still get it verified).
+func NewGuard(issuer *Issuer, anonymousReads bool) *Guard {
+	return &Guard{issuer: issuer, anonymousReads: anonymousReads}
+}
+
+// ClaimsFromContext returns the claims of the token used for a request
+func ClaimsFromContext(ctx context.Context) *Claims {
+	return util.GetFromContext[Claims](ctx, util.ClaimsKey)
+}
+
+// authorize verifies the bearer token found in an Authorization header
+func (g *Guard) authorize(ctx context.Context, authorization string, scope Scope) (context.Context, error) {
+	if scope == Public {
+		return ctx, nil
+	}
+
+	token, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || token == "" {
+		if scope == ScopeRead && g.anonymousReads {
+			return ctx, nil
+		}
+		return ctx, ErrMissingToken
+	}
+
+	claims, err := g.issuer.Verify(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return ctx, err
+	}
+	if !claims.HasScope(scope) {
+		return ctx, ErrScope
+	}
+	return context.WithValue(ctx, util.ClaimsKey, claims), nil
+}
+
+// unauthorized tells token problems (the caller's fault) from lookup failures
+func unauthorized(ctx context.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrMissingToken), errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrExpiredToken), errors.Is(err, ErrRevokedToken),
+		errors.Is(err, ErrScope):
+		return true
+	default:
+		slog.ErrorContext(ctx, "Unable to verify API token", logging.ErrKey, err)
+		return false
+	}
+}
+
+// HTTP only lets requests holding a token with the given scope through
+func (g *Guard) HTTP(scope Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := g.authorize(r.Context(), r.Header.Get("Authorization"), scope)
+		if err != nil {
+			status := http.StatusInternalServerError
+			msg := "unable to verify API token"
+			switch {
+			case errors.Is(err, ErrScope):
+				status, msg = http.StatusForbidden, err.Error()
+			case unauthorized(ctx, err):
+				status, msg = http.StatusUnauthorized, err.Error()
+				w.Header().Set("WWW-Authenticate", `Bearer realm="swincebot"`)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": msg}) //nolint:errcheck
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// grpcAuthorize checks the "authorization" metadata of a gRPC call. Methods
+// missing from scopes are denied.
+func (g *Guard) grpcAuthorize(ctx context.Context, method string, scopes map[string]Scope) (context.Context, error) {
+	scope, known := scopes[method]
+	if !known {
+		return ctx, status.Errorf(codes.PermissionDenied, "%s is not available", method)
+	}
+
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	ctx, err := g.authorize(ctx, authorization, scope)
+	switch {
+	case err == nil:
+		return ctx, nil
+	case errors.Is(err, ErrScope):
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	case unauthorized(ctx, err):
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return ctx, status.Error(codes.Internal, "unable to verify API token")
+	}
+}
+
+// UnaryInterceptor enforces tokens on unary calls. scopes maps full method
+// names to the scope they require.
+func (g *Guard) UnaryInterceptor(scopes map[string]Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := g.grpcAuthorize(ctx, info.FullMethod, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor enforces tokens on streaming calls
+func (g *Guard) StreamInterceptor(scopes map[string]Scope) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := g.grpcAuthorize(ss.Context(), info.FullMethod, scopes)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizedStream carries the verified claims to stream handlers
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,193 @@
+// Package auth mints and verifies the JWTs used to access the HTTP and gRPC APIs
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/google/uuid"
+)
+
+// Issuer signs API tokens with Ed25519 keys kept in the secret vault. A new
+// key is generated every rotation period, older keys are kept around until
+// every token they signed expired.
+type Issuer struct {
+	db       *database.ProtoDB
+	vault    secrets.SecretVault
+	rotation time.Duration
+	maxTTL   time.Duration
+	clock    func() time.Time
+
+	mu   sync.RWMutex
+	keys []signingKey // oldest first, the last one signs new tokens
+}
+
+// NewIssuer loads the signing keys from the vault and rotates them if needed.
+// Tokens can't outlive maxTTL.
+func NewIssuer(db *database.ProtoDB, vault secrets.SecretVault, rotation, maxTTL time.Duration) (*Issuer, error) {
+	keys, err := loadKeys(vault)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Issuer{
+		db:       db,
+		vault:    vault,
+		rotation: rotation,
+		maxTTL:   maxTTL,
+		clock:    time.Now,
+		keys:     keys,
+	}
+	if _, err := i.currentKey(); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// MaxTTL is the longest lifetime a token can be given
+func (i *Issuer) MaxTTL() time.Duration {
+	return i.maxTTL
+}
+
+// currentKey returns the key signing new tokens, rotating the keyring first
+// when that key is too old. Expired keys are dropped along the way.
+func (i *Issuer) currentKey() (signingKey, error) {
+	now := i.clock()
+
+	i.mu.RLock()
+	if n := len(i.keys); n > 0 && now.Sub(i.keys[n-1].Created) < i.rotation {
+		defer i.mu.RUnlock()
+		return i.keys[n-1], nil
+	}
+	i.mu.RUnlock()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if n := len(i.keys); n > 0 && now.Sub(i.keys[n-1].Created) < i.rotation {
+		return i.keys[n-1], nil // rotated while waiting for the lock
+	}
+
+	key, err := newSigningKey(now)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	// A key signs for one rotation period, its tokens live up to maxTTL after that
+	keys := []signingKey{}
+	for _, k := range i.keys {
+		if now.Sub(k.Created) < i.rotation+i.maxTTL {
+			keys = append(keys, k)
+		}
+	}
+	keys = append(keys, key)
+
+	if err := saveKeys(i.vault, keys); err != nil {
+		return signingKey{}, err
+	}
+	i.keys = keys
+
+	slog.Info("Rotated API token signing key", "kid", key.ID, "keys", len(keys))
+	return key, nil
+}
+
+func (i *Issuer) publicKey(kid string) ed25519.PublicKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, k := range i.keys {
+		if k.ID == kid {
+			return k.public()
+		}
+	}
+	return nil
+}
+
+// Mint issues a token for a Discord user and records it so it can be listed
+// and revoked later. A ttl of 0 (or above the maximum) uses the maximum.
+func (i *Issuer) Mint(ctx context.Context, userID uint64, scopes []Scope, ttl time.Duration) (string, *Claims, error) {
+	if len(scopes) == 0 {
+		return "", nil, errors.New("a token needs at least one scope")
+	}
+	if ttl <= 0 || ttl > i.maxTTL {
+		ttl = i.maxTTL
+	}
+
+	key, err := i.currentKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := make([]string, len(scopes))
+	for idx, s := range scopes {
+		names[idx] = string(s)
+	}
+
+	now := i.clock().UTC()
+	claims := &Claims{
+		Issuer:    issuerName,
+		Subject:   strconv.FormatUint(userID, 10),
+		ID:        uuid.NewString(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		Scope:     strings.Join(names, " "),
+	}
+
+	token, err := sign(key, *claims)
+	if err != nil {
+		return "", nil, fmt.Errorf("signing token: %w", err)
+	}
+
+	err = i.db.CreateToken(ctx, database.CreateTokenParams{
+		TokenID:   claims.ID,
+		UserID:    userID,
+		Scopes:    claims.Scope,
+		IssuedAt:  now,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("recording token: %w", err)
+	}
+
+	return token, claims, nil
+}
+
+// Verify checks a token's signature, expiry and revocation status
+func (i *Issuer) Verify(ctx context.Context, token string) (*Claims, error) {
+	claims, err := parse(token, i.publicKey, i.clock())
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := i.db.GetToken(ctx, claims.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown token", ErrInvalidToken)
+	} else if err != nil {
+		return nil, fmt.Errorf("looking up token: %w", err)
+	}
+	if record.RevokedAt.Valid {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// Revoke invalidates a token. It reports false when the token doesn't exist
+// or was already revoked.
+func (i *Issuer) Revoke(ctx context.Context, tokenID string) (bool, error) {
+	n, err := i.db.RevokeToken(ctx, database.RevokeTokenParams{
+		RevokedAt: sql.NullTime{Time: i.clock().UTC(), Valid: true},
+		TokenID:   tokenID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("revoking token: %w", err)
+	}
+	return n > 0, nil
+}
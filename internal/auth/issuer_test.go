@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+)
+
+const (
+	testRotation = 24 * time.Hour
+	testMaxTTL   = 48 * time.Hour
+)
+
+// newTestIssuer returns an issuer backed by a fresh database and vault, along
+// with the time its clock reports
+func newTestIssuer(t *testing.T) (*Issuer, *time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := database.Setup(context.Background(), filepath.Join(dir, "swincebot.db"), &util.ConfigStore{DBCacheSize: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	vault, err := secrets.NewDirVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer, err := NewIssuer(db, vault, testRotation, testMaxTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	issuer.clock = func() time.Time { return now }
+	// The first key was created with the real clock
+	issuer.keys[0].Created = now
+	return issuer, &now
+}
+
+func TestIssuerVerify(t *testing.T) {
+	ctx := context.Background()
+	issuer, now := newTestIssuer(t)
+
+	token, claims, err := issuer.Mint(ctx, 42, []Scope{ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := issuer.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify returned %v", err)
+	} else if got.UserID() != 42 || got.ID != claims.ID {
+		t.Errorf("Verify returned the claims of user %d token %s, want user 42 token %s", got.UserID(), got.ID, claims.ID)
+	}
+
+	// Tokens never recorded (ex: minted against another database) are refused
+	unrecorded := *claims
+	unrecorded.ID = "unrecorded"
+	key, err := issuer.currentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := sign(key, unrecorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(ctx, forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify of an unrecorded token returned %v, want ErrInvalidToken", err)
+	}
+
+	if revoked, err := issuer.Revoke(ctx, claims.ID); err != nil || !revoked {
+		t.Fatalf("Revoke = %v, %v", revoked, err)
+	}
+	if _, err := issuer.Verify(ctx, token); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("Verify of a revoked token returned %v, want ErrRevokedToken", err)
+	}
+	if revoked, err := issuer.Revoke(ctx, claims.ID); err != nil || revoked {
+		t.Errorf("revoking twice = %v, %v, want false", revoked, err)
+	}
+
+	expiring, _, err := issuer.Mint(ctx, 42, []Scope{ScopeRead}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(time.Hour)
+	if _, err := issuer.Verify(ctx, expiring); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("Verify of an expired token returned %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestIssuerRotation(t *testing.T) {
+	ctx := context.Background()
+	issuer, now := newTestIssuer(t)
+	start := *now
+
+	old, oldClaims, err := issuer.Mint(ctx, 42, []Scope{ScopeRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Unix(oldClaims.ExpiresAt, 0); !got.Equal(start.Add(testMaxTTL)) {
+		t.Errorf("a ttl of 0 expires at %v, want the maximum (%v)", got, start.Add(testMaxTTL))
+	}
+	oldKey := issuer.keys[0]
+
+	tests := []struct {
+		name  string
+		after time.Duration // since the old key was created
+		keys  int           // in the keyring once rotated
+		want  error         // verifying a token signed by the old key
+	}{
+		{"before rotation", testRotation - time.Minute, 1, nil},
+		{"rotated out, tokens still valid", testRotation + time.Minute, 2, nil},
+		{"rotated out, tokens expired", testMaxTTL + time.Minute, 3, ErrExpiredToken},
+		{"past rotation+maxTTL", testRotation + testMaxTTL + time.Minute, 3, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*now = start.Add(tt.after)
+			if _, _, err := issuer.Mint(ctx, 43, []Scope{ScopeRead}, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if len(issuer.keys) != tt.keys {
+				t.Errorf("keyring holds %d keys, want %d", len(issuer.keys), tt.keys)
+			}
+
+			_, err := issuer.Verify(ctx, old)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify of a token signed by the old key returned %v, want %v", err, tt.want)
+			}
+
+			// A token the old key signed for longer than allowed only lives
+			// as long as the key stays in the keyring
+			long := *oldClaims
+			long.ExpiresAt = start.Add(10 * testMaxTTL).Unix()
+			token, err := sign(oldKey, long)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = issuer.Verify(ctx, token)
+			if dropped := tt.after >= testRotation+testMaxTTL; dropped != errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify after %v returned %v (key dropped: %v)", tt.after, err, dropped)
+			}
+		})
+	}
+
+}
+
+func TestGuardAuthorize(t *testing.T) {
+	ctx := context.Background()
+	issuer, _ := newTestIssuer(t)
+
+	read, _, err := issuer.Mint(ctx, 42, []Scope{ScopeRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit, _, err := issuer.Mint(ctx, 42, []Scope{ScopeRead, ScopeSubmit}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		scope          Scope
+		anonymousReads bool
+		want           error
+	}{
+		{"public", "", Public, false, nil},
+		{"read with read scope", "Bearer " + read, ScopeRead, false, nil},
+		{"submit with read scope", "Bearer " + read, ScopeSubmit, false, ErrScope},
+		{"submit with submit scope", "Bearer " + submit, ScopeSubmit, false, nil},
+		{"admin with submit scope", "Bearer " + submit, ScopeAdmin, false, ErrScope},
+		{"missing token", "", ScopeRead, false, ErrMissingToken},
+		{"anonymous read", "", ScopeRead, true, nil},
+		{"anonymous submit", "", ScopeSubmit, true, ErrMissingToken},
+		{"not a bearer token", "Basic " + read, ScopeRead, false, ErrMissingToken},
+		{"invalid token with anonymous reads", "Bearer garbage", ScopeRead, true, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGuard(issuer, tt.anonymousReads)
+			_, err := g.authorize(ctx, tt.authorization, tt.scope)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("authorize returned %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
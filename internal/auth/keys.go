@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/google/uuid"
+)
+
+// keyringSecret is where the signing keys are persisted in the vault
+const keyringSecret = "jwt_signing_keys"
+
+// signingKey is an Ed25519 key pair (stored as its seed) identified by the
+// kid header of the tokens it signs
+type signingKey struct {
+	ID      string    `json:"kid"`
+	Seed    []byte    `json:"seed"`
+	Created time.Time `json:"created"`
+}
+
+func (k signingKey) private() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.Seed)
+}
+
+func (k signingKey) public() ed25519.PublicKey {
+	return k.private().Public().(ed25519.PublicKey)
+}
+
+func newSigningKey(now time.Time) (signingKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return signingKey{}, fmt.Errorf("generating signing key: %w", err)
+	}
+	return signingKey{
+		ID:      uuid.NewString(),
+		Seed:    seed,
+		Created: now.UTC(),
+	}, nil
+}
+
+// loadKeys reads the keyring from the vault (oldest key first). A missing
+// keyring is not an error: it gets created on the first rotation.
+func loadKeys(vault secrets.SecretVault) ([]signingKey, error) {
+	secret, err := vault.Get(keyringSecret)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading signing keys: %w", err)
+	}
+
+	var keys []signingKey
+	if err := json.Unmarshal(secret.Bytes(), &keys); err != nil {
+		return nil, fmt.Errorf("parsing signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+func saveKeys(vault secrets.SecretVault, keys []signingKey) error {
+	buf, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("encoding signing keys: %w", err)
+	}
+	if err := vault.Set(keyringSecret, secrets.Secret(buf)); err != nil {
+		return fmt.Errorf("saving signing keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scope restricts what an API token may be used for
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"   // scores, seasons and events
+	ScopeSubmit Scope = "submit" // submitting swinces on behalf of the token's owner
+	ScopeAdmin  Scope = "admin"  // operating the bot (log levels), only minted for operators
+)
+
+// Scopes lists every known scope
+var Scopes = []Scope{ScopeRead, ScopeSubmit, ScopeAdmin}
+
+const issuerName = "swincebot"
+
+var (
+	ErrMissingToken = errors.New("missing API token")
+	ErrInvalidToken = errors.New("invalid API token")
+	ErrExpiredToken = errors.New("API token expired")
+	ErrRevokedToken = errors.New("API token was revoked")
+	ErrScope        = errors.New("API token lacks the required scope")
+)
+
+// Claims are the JWT claims of an API token
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"` // Discord user ID of the owner
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Scope     string `json:"scope"` // space separated scopes
+}
+
+// UserID returns the Discord user the token belongs to
+func (c *Claims) UserID() uint64 {
+	id, _ := strconv.ParseUint(c.Subject, 10, 64)
+	return id
+}
+
+func (c *Claims) HasScope(scope Scope) bool {
+	return slices.Contains(strings.Fields(c.Scope), string(scope))
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// sign encodes the claims as a compact EdDSA JWT
+func sign(key signingKey, claims Claims) (string, error) {
+	h, err := json.Marshal(header{Alg: "EdDSA", Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	sig := ed25519.Sign(key.private(), []byte(signed))
+	return signed + "." + b64.EncodeToString(sig), nil
+}
+
+// parse checks the signature and expiry of a token. keyFor returns the public
+// key matching a kid (nil when unknown).
+func parse(token string, keyFor func(kid string) ed25519.PublicKey, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	if h.Alg != "EdDSA" {
+		return nil, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, h.Alg)
+	}
+	key := keyFor(h.Kid)
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown signing key", ErrInvalidToken)
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != issuerName || claims.ID == "" {
+		return nil, fmt.Errorf("%w: unexpected claims", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	buf, err := b64.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	key, err := newSigningKey(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newSigningKey(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFor := func(kid string) ed25519.PublicKey {
+		if kid == key.ID {
+			return key.public()
+		}
+		return nil
+	}
+
+	valid := Claims{
+		Issuer:    issuerName,
+		Subject:   "42",
+		ID:        "token",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		Scope:     "read",
+	}
+	mustSign := func(k signingKey, c Claims) string {
+		token, err := sign(k, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// withSegment replaces one part of a signed token, keeping its signature
+	withSegment := func(token string, idx int, v any) string {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(token, ".")
+		parts[idx] = b64.EncodeToString(buf)
+		return strings.Join(parts, ".")
+	}
+
+	expired := valid
+	expired.ExpiresAt = now.Unix()
+	tampered := valid
+	tampered.Scope = "read submit admin"
+	foreign := valid
+	foreign.Issuer = "someone else"
+	unknownKey := other
+	unknownKey.ID = "unknown"
+
+	tests := []struct {
+		name  string
+		token string
+		want  error // nil when the token is valid
+	}{
+		{"valid", mustSign(key, valid), nil},
+		{"malformed", "not a token", ErrInvalidToken},
+		{"wrong alg", withSegment(mustSign(key, valid), 0, header{Alg: "none", Typ: "JWT", Kid: key.ID}), ErrInvalidToken},
+		{"HMAC alg", withSegment(mustSign(key, valid), 0, header{Alg: "HS256", Typ: "JWT", Kid: key.ID}), ErrInvalidToken},
+		{"unknown kid", mustSign(unknownKey, valid), ErrInvalidToken},
+		{"signed by another key", withSegment(mustSign(other, valid), 0, header{Alg: "EdDSA", Typ: "JWT", Kid: key.ID}), ErrInvalidToken},
+		{"tampered payload", withSegment(mustSign(key, valid), 1, tampered), ErrInvalidToken},
+		{"stripped signature", strings.Join(strings.Split(mustSign(key, valid), ".")[:2], ".") + ".", ErrInvalidToken},
+		{"foreign issuer", mustSign(key, foreign), ErrInvalidToken},
+		{"expired", mustSign(key, expired), ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parse(tt.token, keyFor, now)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("parse returned %v", err)
+				}
+				if *claims != valid {
+					t.Errorf("parse returned %+v, want %+v", *claims, valid)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("parse returned %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimsHasScope(t *testing.T) {
+	tests := []struct {
+		scopes string
+		scope  Scope
+		want   bool
+	}{
+		{"read", ScopeRead, true},
+		{"read submit", ScopeSubmit, true},
+		{"read", ScopeSubmit, false},
+		{"read submit", ScopeAdmin, false},
+		{"readsubmit", ScopeRead, false},
+		{"", ScopeRead, false},
+	}
+
+	for _, tt := range tests {
+		c := Claims{Scope: tt.scopes}
+		if got := c.HasScope(tt.scope); got != tt.want {
+			t.Errorf("Claims{Scope: %q}.HasScope(%q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package backfill
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/transfer"
+	"github.com/google/uuid"
+)
+
+// Conflict is a record (or part of one) that could not be imported as is
+type Conflict struct {
+	Source string
+	Reason string
+}
+
+// Report summarizes what a backfill did (or would do)
+type Report struct {
+	Events    int
+	Swinces   int
+	Explicit  int // fulfillment links given by the records
+	Inferred  int // fulfillment links guessed from nominee/deadline matching
+	Conflicts []Conflict
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d event(s), %d swince(s), %d explicit and %d inferred fulfillment(s), %d conflict(s)\n",
+		r.Events, r.Swinces, r.Explicit, r.Inferred, len(r.Conflicts)))
+	for _, c := range r.Conflicts {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", c.Source, c.Reason))
+	}
+	return sb.String()
+}
+
+// nomination is a swince of the dump whose nominee hasn't answered yet
+type nomination struct {
+	swince    int // index in Dump.Swinces
+	eventTime time.Time
+	nominator uint64
+	nominee   uint64
+}
+
+// Build turns a guild's historical records into a dump ready for
+// transfer.Import (the guild itself must already be configured). Records conflicting with an event already recorded at the same time, or
+// naming people who can't be resolved, are skipped and reported.
+// Fulfillments are inferred by matching a participant to the oldest open
+// nomination they received less than deadline before their swince.
+func Build(guildID uint64, records []Record, dir *Directory, deadline time.Duration, existing []time.Time) (*transfer.Dump, Report) {
+	d := &transfer.Dump{Version: transfer.Version}
+	var (
+		report Report
+		open   []nomination
+	)
+	conflict := func(source, format string, args ...any) {
+		report.Conflicts = append(report.Conflicts, Conflict{Source: source, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	records = slices.Clone(records)
+	slices.SortStableFunc(records, func(a, b Record) int { return a.Time.Compare(b.Time) })
+
+	for _, rec := range records {
+		if slices.ContainsFunc(existing, rec.Time.Equal) {
+			conflict(rec.Source, "an event was already recorded at %s", rec.Time.Format(time.RFC3339))
+			continue
+		}
+
+		participants, nominees, err := resolveRecord(rec, dir)
+		if err != nil {
+			conflict(rec.Source, "%v", err)
+			continue
+		}
+
+		eventID := uuid.NewString()
+		d.Events = append(d.Events, transfer.Event{
+			EventID:      eventID,
+			GuildID:      guildID,
+			Time:         rec.Time,
+			Proof:        rec.Proof,
+			Verification: database.VerificationApproved,
+		})
+		report.Events++
+
+		var fresh []nomination
+		for i, participant := range participants {
+			swinceID := uuid.NewString()
+			d.Swinces = append(d.Swinces, transfer.Swince{
+				EventID:       eventID,
+				GuildID:       guildID,
+				SwinceID:      swinceID,
+				ParticipantID: participant,
+				NomineeID:     nominees[i],
+			})
+			report.Swinces++
+			if nominees[i] != nil {
+				fresh = append(fresh, nomination{
+					swince:    len(d.Swinces) - 1,
+					eventTime: rec.Time,
+					nominator: participant,
+					nominee:   *nominees[i],
+				})
+			}
+
+			// Explicitly named nominator
+			if i < len(rec.Fulfills) && !isNoOne(rec.Fulfills[i]) {
+				nominator, err := dir.Resolve(rec.Fulfills[i])
+				if err != nil {
+					conflict(rec.Source, "fulfillment of %s: %v", rec.Participants[i], err)
+					continue
+				}
+				idx := slices.IndexFunc(open, func(n nomination) bool {
+					return n.nominator == nominator && n.nominee == participant
+				})
+				if idx == -1 {
+					conflict(rec.Source, "%s answers %s but no such open nomination exists", rec.Participants[i], rec.Fulfills[i])
+					continue
+				}
+				d.Swinces[open[idx].swince].FulfillmentID = &swinceID
+				open = slices.Delete(open, idx, idx+1)
+				report.Explicit++
+				continue
+			}
+
+			// Inferred: oldest nomination received within the deadline
+			idx := slices.IndexFunc(open, func(n nomination) bool {
+				return n.nominee == participant && rec.Time.Sub(n.eventTime) <= deadline
+			})
+			if idx != -1 {
+				d.Swinces[open[idx].swince].FulfillmentID = &swinceID
+				open = slices.Delete(open, idx, idx+1)
+				report.Inferred++
+			}
+		}
+
+		// Nominations of this event can only be answered by later events
+		open = append(open, fresh...)
+	}
+
+	return d, report
+}
+
+// resolveRecord turns every name of a record into a user ID
+func resolveRecord(rec Record, dir *Directory) ([]uint64, []*uint64, error) {
+	if len(rec.Participants) == 0 {
+		return nil, nil, fmt.Errorf("no participants")
+	}
+	if len(rec.Nominees) > len(rec.Participants) {
+		return nil, nil, fmt.Errorf("%d nominees for %d participants", len(rec.Nominees), len(rec.Participants))
+	}
+
+	participants := make([]uint64, len(rec.Participants))
+	for i, name := range rec.Participants {
+		id, err := dir.Resolve(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("participant: %w", err)
+		}
+		if slices.Contains(participants[:i], id) {
+			return nil, nil, fmt.Errorf("participant %q is listed twice", name)
+		}
+		participants[i] = id
+	}
+
+	nominees := make([]*uint64, len(rec.Participants))
+	for i, name := range rec.Nominees {
+		if isNoOne(name) {
+			continue
+		}
+		id, err := dir.Resolve(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("nominee: %w", err)
+		}
+		if id == participants[i] {
+			return nil, nil, fmt.Errorf("%q nominated themselves", name)
+		}
+		nominees[i] = &id
+	}
+
+	return participants, nominees, nil
+}
@@ -0,0 +1,201 @@
+package backfill
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Record is one historical swince event before names get resolved.
+// Nominees and Fulfills line up with Participants: Nominees[i] is who
+// Participants[i] nominated ("" or "none" for no-one) and Fulfills[i] is whose
+// nomination they answered ("" to let the importer infer it).
+type Record struct {
+	Source       string // where the record came from (for the conflict report)
+	Time         time.Time
+	Participants []string
+	Nominees     []string
+	Fulfills     []string
+	Proof        *int64 // discord message ID, when known
+}
+
+// timeLayouts are accepted in spreadsheets, from most to least precise
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseSheetTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
+
+// splitCell splits a spreadsheet cell holding several names ("alice; bob").
+// Empty entries are kept so columns stay aligned ("bob;;carl").
+func splitCell(cell string) []string {
+	if strings.TrimSpace(cell) == "" {
+		return nil
+	}
+	names := strings.Split(strings.ReplaceAll(cell, "|", ";"), ";")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return names
+}
+
+// ReadSheetCSV reads a spreadsheet export with the columns
+// date, participants, nominees, fulfills (header required, names separated by ';')
+func ReadSheetCSV(r io.Reader) ([]Record, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading spreadsheet: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "participants"} {
+		if _, exists := columns[required]; !exists {
+			return nil, fmt.Errorf("spreadsheet is missing the %q column", required)
+		}
+	}
+	cell := func(row []string, column string) string {
+		if i, exists := columns[column]; exists && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	records := make([]Record, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		source := fmt.Sprintf("line %d", i+2)
+		t, err := parseSheetTime(cell(row, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		records = append(records, Record{
+			Source:       source,
+			Time:         t,
+			Participants: splitCell(cell(row, "participants")),
+			Nominees:     splitCell(cell(row, "nominees")),
+			Fulfills:     splitCell(cell(row, "fulfills")),
+		})
+	}
+	return records, nil
+}
+
+// sheetRow is the JSON flavour of a spreadsheet row
+type sheetRow struct {
+	Date         string   `json:"date"`
+	Participants []string `json:"participants"`
+	Nominees     []string `json:"nominees"`
+	Fulfills     []string `json:"fulfills"`
+}
+
+// ReadSheetJSON reads an array of {date, participants, nominees, fulfills} objects
+func ReadSheetJSON(r io.Reader) ([]Record, error) {
+	var rows []sheetRow
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("reading spreadsheet: %w", err)
+	}
+
+	records := make([]Record, 0, len(rows))
+	for i, row := range rows {
+		source := fmt.Sprintf("entry %d", i+1)
+		t, err := parseSheetTime(row.Date)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		records = append(records, Record{
+			Source:       source,
+			Time:         t,
+			Participants: row.Participants,
+			Nominees:     row.Nominees,
+			Fulfills:     row.Fulfills,
+		})
+	}
+	return records, nil
+}
+
+// nominationLine matches "<@participant> nominates <@nominee>" lines, which
+// is how swinces were announced on the channel (and how the bot reposts them)
+var nominationLine = regexp.MustCompile(`<@!?(\d+)>\s+nominates?\s+<@!?(\d+)>`)
+
+// ReadChannelDump reads a JSON array of Discord messages (as returned by the
+// API). Every message carrying a video is a swince. Participants and nominees
+// come from "<@a> nominates <@b>" lines when present, otherwise the author
+// swinced and nominated the first user they mentioned.
+func ReadChannelDump(r io.Reader) ([]Record, error) {
+	var messages []discordgo.Message
+	if err := json.NewDecoder(r).Decode(&messages); err != nil {
+		return nil, fmt.Errorf("reading channel dump: %w", err)
+	}
+
+	var records []Record
+	for _, m := range messages {
+		if m.Author == nil || (m.Author.Bot && !nominationLine.MatchString(m.Content)) || !hasVideo(m) {
+			continue
+		}
+
+		rec := Record{
+			Source: fmt.Sprintf("message %s", m.ID),
+			Time:   m.Timestamp.UTC(),
+		}
+		if id, err := strconv.ParseInt(m.ID, 10, 64); err == nil {
+			rec.Proof = &id
+		}
+
+		if matches := nominationLine.FindAllStringSubmatch(m.Content, -1); len(matches) > 0 {
+			for _, match := range matches {
+				rec.Participants = append(rec.Participants, mention(match[1]))
+				rec.Nominees = append(rec.Nominees, mention(match[2]))
+			}
+		} else {
+			rec.Participants = []string{mention(m.Author.ID)}
+			rec.Nominees = []string{""}
+			for _, u := range m.Mentions {
+				if u.ID != m.Author.ID && !u.Bot {
+					rec.Nominees[0] = mention(u.ID)
+					break
+				}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func hasVideo(m discordgo.Message) bool {
+	for _, a := range m.Attachments {
+		if strings.HasPrefix(a.ContentType, "video/") {
+			return true
+		}
+	}
+	for _, e := range m.Embeds {
+		if e.Video != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func mention(id string) string {
+	return "<@" + id + ">"
+}
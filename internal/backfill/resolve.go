@@ -0,0 +1,74 @@
+package backfill
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+)
+
+var (
+	errUnknownName   = errors.New("no server member goes by that name")
+	errAmbiguousName = errors.New("several server members go by that name")
+)
+
+// Directory resolves the names people used by hand to Discord user IDs
+type Directory struct {
+	byName map[string][]uint64
+}
+
+// NewDirectory indexes members by nickname and username (case insensitive)
+func NewDirectory(members []discord.User) *Directory {
+	d := &Directory{byName: make(map[string][]uint64)}
+	for _, m := range members {
+		for _, name := range []string{m.Nick, m.Username} {
+			key := normalizeName(name)
+			if key == "" || containsID(d.byName[key], m.ID) {
+				continue
+			}
+			d.byName[key] = append(d.byName[key], m.ID)
+		}
+	}
+	return d
+}
+
+// Resolve accepts a name, an @name, a raw user ID or a <@mention>
+func (d *Directory) Resolve(name string) (uint64, error) {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(name), "<@"), "!"), ">")
+	if id, err := strconv.ParseUint(trimmed, 10, 64); err == nil {
+		return id, nil
+	}
+
+	switch ids := d.byName[normalizeName(name)]; len(ids) {
+	case 0:
+		return 0, fmt.Errorf("%w: %q", errUnknownName, name)
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%w: %q", errAmbiguousName, name)
+	}
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "@"))
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+// isNoOne reports whether a nominee cell means "swinced for no-one"
+func isNoOne(name string) bool {
+	switch normalizeName(name) {
+	case "", "none", "no-one", "noone", "nobody", "-":
+		return true
+	}
+	return false
+}
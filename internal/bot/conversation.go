@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/ChausseBenjamin/swincebot/internal/tracing"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+type convStep uint8
+
+const (
+	stepParticipants convStep = iota
+	stepNominations
+	stepProof
+)
+
+// conversation holds the state of a swince submission happening in a user's DMs
+type conversation struct {
+	mu sync.Mutex
+
+	guildID   uint64 // server the swince gets posted on
+	userID    string
+	channelID string // DM channel with the submitter
+	step      convStep
+
+	participants []uint64
+	nominees     map[uint64]*uint64
+	current      int // index of the participant whose nomination is being asked
+
+	timer *time.Timer
+	done  bool
+}
+
+func (c *conversation) currentParticipant() uint64 {
+	return c.participants[c.current]
+}
+
+// startConversation opens a DM with the user and asks for the first missing piece
+// of information. Any previous conversation with that user is discarded (even
+// one started from another server).
+func (b *Bot) startConversation(ctx context.Context, s *discordgo.Session, guildID uint64, userID string, seed []uint64) error {
+	dm, err := s.UserChannelCreate(userID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("opening DM channel: %w", err)
+	}
+
+	conv := &conversation{
+		guildID:   guildID,
+		userID:    userID,
+		channelID: dm.ID,
+		nominees:  make(map[uint64]*uint64),
+	}
+	if len(seed) > 0 {
+		conv.participants = seed
+		conv.step = stepNominations
+	}
+
+	b.convMu.Lock()
+	if previous, exists := b.conversations[userID]; exists {
+		previous.timer.Stop()
+		metrics.Conversations.Inc(metrics.ConversationRestarted)
+	}
+	b.conversations[userID] = conv
+	conv.timer = time.AfterFunc(b.cfg.ConversationTimeout, func() {
+		b.expireConversation(s, conv)
+	})
+	b.convMu.Unlock()
+
+	b.prompt(ctx, s, conv)
+	return nil
+}
+
+// endConversation forgets about a conversation (completed, cancelled or
+// expired), outcome being one of the metrics.Conversation* constants
+func (b *Bot) endConversation(conv *conversation, outcome string) {
+	b.convMu.Lock()
+	defer b.convMu.Unlock()
+
+	metrics.Conversations.Inc(outcome)
+	conv.done = true
+	conv.timer.Stop()
+	if b.conversations[conv.userID] == conv {
+		delete(b.conversations, conv.userID)
+	}
+}
+
+func (b *Bot) expireConversation(s *discordgo.Session, conv *conversation) {
+	if !b.work.begin() {
+		return
+	}
+	defer b.work.end()
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	if conv.done {
+		return
+	}
+
+	ctx, span := tracing.Start(context.Background(), tracing.KindInternal, "conversation timeout")
+	defer span.End()
+
+	b.endConversation(conv, metrics.ConversationTimedOut)
+	logger.InfoContext(ctx, "Swince conversation timed out", "user_id", conv.userID)
+	b.reply(ctx, s, conv, ":hourglass: This swince submission timed out. Use `/swince` to start over.")
+}
+
+func (b *Bot) handleDirectMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.Bot || m.GuildID != "" {
+		return
+	}
+
+	b.convMu.Lock()
+	conv, exists := b.conversations[m.Author.ID]
+	b.convMu.Unlock()
+	if !exists {
+		return
+	}
+
+	if !b.work.begin() {
+		return // the conversation gets interrupted by Shutdown
+	}
+	defer b.work.end()
+
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+	if conv.done {
+		return
+	}
+	conv.timer.Reset(b.cfg.ConversationTimeout)
+
+	ctx := context.WithValue(context.Background(), util.ReqIDKey, m.ID)
+	ctx, span := tracing.Start(ctx, tracing.KindServer, "direct message",
+		slog.String("discord.message_id", m.ID),
+		slog.Int("conversation.step", int(conv.step)),
+	)
+	defer span.End()
+
+	if strings.EqualFold(strings.TrimSpace(m.Content), "cancel") {
+		b.endConversation(conv, metrics.ConversationCancelled)
+		logger.InfoContext(ctx, "Swince conversation cancelled", "user_id", conv.userID)
+		b.reply(ctx, s, conv, ":x: Swince submission cancelled.")
+		return
+	}
+
+	switch conv.step {
+	case stepParticipants:
+		b.collectParticipants(ctx, s, conv, m.Message)
+	case stepNominations:
+		b.collectNomination(ctx, s, conv, m.Message)
+	case stepProof:
+		b.collectProof(ctx, s, conv, m.Message)
+	}
+}
+
+func (b *Bot) collectParticipants(ctx context.Context, s *discordgo.Session, conv *conversation, m *discordgo.Message) {
+	seen := make(map[uint64]bool)
+	for _, field := range strings.Fields(strings.ToLower(m.Content)) {
+		if field == "me" {
+			if id, err := strconv.ParseUint(m.Author.ID, 10, 64); err == nil && !seen[id] {
+				seen[id] = true
+				conv.participants = append(conv.participants, id)
+			}
+		}
+	}
+	for _, u := range m.Mentions {
+		if u.Bot {
+			continue
+		}
+		id, err := strconv.ParseUint(u.ID, 10, 64)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		conv.participants = append(conv.participants, id)
+	}
+
+	if len(conv.participants) == 0 {
+		b.reply(ctx, s, conv, "I couldn't find anyone in that message. Mention everyone who swinced (type `me` to include yourself).")
+		return
+	}
+
+	conv.step = stepNominations
+	b.prompt(ctx, s, conv)
+}
+
+func (b *Bot) collectNomination(ctx context.Context, s *discordgo.Session, conv *conversation, m *discordgo.Message) {
+	participant := conv.currentParticipant()
+
+	switch content := strings.ToLower(strings.TrimSpace(m.Content)); {
+	case content == "none" || content == "no-one" || content == "noone":
+		conv.nominees[participant] = nil
+	case len(m.Mentions) == 1 && !m.Mentions[0].Bot:
+		nominee, err := strconv.ParseUint(m.Mentions[0].ID, 10, 64)
+		if err != nil {
+			logger.WarnContext(ctx, "failed to parse nominee ID", "user_id", m.Mentions[0].ID, logging.ErrKey, err)
+			b.reply(ctx, s, conv, "I couldn't understand that nomination, please try again.")
+			return
+		}
+		if nominee == participant {
+			b.reply(ctx, s, conv, "Nice try, but you can't nominate yourself :wink:")
+			return
+		}
+		conv.nominees[participant] = &nominee
+	default:
+		b.reply(ctx, s, conv, "Mention exactly **one** person, or type `none` to swince for no-one.")
+		return
+	}
+
+	conv.current++
+	if conv.current >= len(conv.participants) {
+		conv.step = stepProof
+	}
+	b.prompt(ctx, s, conv)
+}
+
+func (b *Bot) collectProof(ctx context.Context, s *discordgo.Session, conv *conversation, m *discordgo.Message) {
+	p, err := b.proofFromMessage(ctx, m)
+	if err != nil {
+		logger.InfoContext(ctx, "Rejected swince proof", "user_id", conv.userID, logging.ErrKey, err)
+		b.reply(ctx, s, conv, fmt.Sprintf(":no_entry: %s. Upload a video (or paste a direct link to one) to continue.", proofErrorMessage(err)))
+		return
+	}
+
+	if _, err := b.submit(ctx, s, conv, p); err != nil {
+		logger.ErrorContext(ctx, "Failed to submit swince", "user_id", conv.userID, logging.ErrKey, err)
+		b.reply(ctx, s, conv, ":warning: Something went wrong while submitting your swince. Please try again later.")
+		b.endConversation(conv, metrics.ConversationFailed)
+		return
+	}
+
+	b.endConversation(conv, metrics.ConversationCompleted)
+	b.reply(ctx, s, conv, ":white_check_mark: Swince submitted! Cheers :beers:")
+}
+
+// prompt asks the user for whatever the conversation currently needs
+func (b *Bot) prompt(ctx context.Context, s *discordgo.Session, conv *conversation) {
+	switch conv.step {
+	case stepParticipants:
+		b.reply(ctx, s, conv, "Who swinced in this video? Mention everyone who took part (type `me` to include yourself).")
+	case stepNominations:
+		b.reply(ctx, s, conv, fmt.Sprintf("Who does <@%d> nominate? Mention one person, or type `none` to swince for no-one.",
+			conv.currentParticipant()))
+	case stepProof:
+		b.reply(ctx, s, conv, fmt.Sprintf("Last step: upload the video as proof (or paste a direct link to it). Max size: %d MB.",
+			b.cfg.ProofMaxSize/(1<<20)))
+	}
+}
+
+// reply queues a DM to the user, after the previous ones
+func (b *Bot) reply(ctx context.Context, s *discordgo.Session, conv *conversation, content string) {
+	err := b.outbox.Enqueue(ctx, discord.Message{
+		ChannelID: conv.channelID,
+		UserID:    conv.userID,
+		Send:      &discordgo.MessageSend{Content: content},
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to send DM", "user_id", conv.userID, logging.ErrKey, err)
+	}
+}
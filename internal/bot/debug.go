@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+// debugCommand registers /debug loglevel. Its subsystem choices are the names
+// given to logging.Subsystem: db, bot and ruleset.
+func debugCommand() *discordgo.ApplicationCommand {
+	subsystems := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, name := range logging.Subsystems() {
+		subsystems = append(subsystems, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: name})
+	}
+
+	return &discordgo.ApplicationCommand{
+		Name:        "debug",
+		Description: "Troubleshoot the bot (operators only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "loglevel",
+				Description: "Show or change how verbose the logs are",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "level",
+						Description: "New level (leave empty to show the current ones)",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "debug", Value: "debug"},
+							{Name: "info", Value: "info"},
+							{Name: "warn", Value: "warn"},
+							{Name: "error", Value: "error"},
+							{Name: "reset", Value: logging.LevelReset},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "subsystem",
+						Description: "Only change the level of this part of the bot",
+						Choices:     subsystems,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (b *Bot) handleDebugCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// The log level is shared by every server: guild admins don't qualify
+	if !b.isOperator(i) {
+		b.respondEphemeral(ctx, s, i, ":lock: Only the bot's operators can debug it.")
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	var level, subsystem string
+	for _, opt := range sub.Options {
+		switch opt.Name {
+		case "level":
+			level = opt.StringValue()
+		case "subsystem":
+			subsystem = opt.StringValue()
+		}
+	}
+
+	if level != "" {
+		if err := logging.ChangeLevel(subsystem, level); err != nil {
+			b.respondEphemeral(ctx, s, i, fmt.Sprintf(":warning: %v", err))
+			return
+		}
+		logger.WarnContext(ctx, "Log level changed", "new_level", level, "subsystem", subsystem, "user_id", i.Member.User.ID)
+	}
+
+	b.respondEphemeral(ctx, s, i, describeLevels(logging.Levels()))
+}
+
+func describeLevels(state logging.LevelState) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(":mag: Logging at **%s**", state.Global))
+	for _, name := range logging.Subsystems() {
+		if lvl, set := state.Subsystems[name]; set {
+			sb.WriteString(fmt.Sprintf("\n- `%s` at **%s**", name, lvl))
+		}
+	}
+	return sb.String()
+}
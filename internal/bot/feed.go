@@ -0,0 +1,54 @@
+package bot
+
+import "sync"
+
+// feedBuffer is how many events a slow subscriber may lag behind before
+// missing some
+const feedBuffer = 16
+
+// feed fans out the IDs of newly recorded events to its subscribers
+type feed struct {
+	mu   sync.Mutex
+	subs map[chan string]struct{}
+}
+
+func newFeed() *feed {
+	return &feed{subs: make(map[chan string]struct{})}
+}
+
+func (f *feed) subscribe() (<-chan string, func()) {
+	ch := make(chan string, feedBuffer)
+
+	f.mu.Lock()
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subs, ch)
+			f.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish never blocks: subscribers with a full buffer miss the event
+func (f *feed) publish(eventID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for ch := range f.subs {
+		select {
+		case ch <- eventID:
+		default:
+			logger.Warn("Event feed subscriber is lagging behind, dropping event", "event_id", eventID)
+		}
+	}
+}
+
+// Subscribe returns the IDs of events recorded from now on. Call the returned
+// function to stop receiving them.
+func (b *Bot) Subscribe() (<-chan string, func()) {
+	return b.events.subscribe()
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+)
+
+// inflight tracks the work the bot must finish before shutting down:
+// interactions, DMs and proof archiving
+type inflight struct {
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+}
+
+// begin registers new work, unless the bot is shutting down. Every
+// successful call must be followed by end.
+func (f *inflight) begin() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closing {
+		return false
+	}
+	f.wg.Add(1)
+	return true
+}
+
+func (f *inflight) end() {
+	f.wg.Done()
+}
+
+// drain refuses new work and waits for the current one
+func (f *inflight) drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.closing = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for in-flight interactions: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops handling interactions, waits for those in progress and
+// tells users whose submission got interrupted. The Discord session is left
+// open until queued messages are sent: close it afterwards.
+func (b *Bot) Shutdown(ctx context.Context) error {
+	err := b.work.drain(ctx)
+	b.interruptConversations(ctx)
+	return errors.Join(err, b.outbox.Close(ctx))
+}
+
+// interruptConversations ends every conversation still in progress. A
+// conversation can't be resumed by another process: its users are asked to
+// start over instead.
+func (b *Bot) interruptConversations(ctx context.Context) {
+	b.convMu.Lock()
+	conversations := make([]*conversation, 0, len(b.conversations))
+	for _, conv := range b.conversations {
+		conversations = append(conversations, conv)
+	}
+	b.convMu.Unlock()
+
+	for _, conv := range conversations {
+		// A handler which outlived the drain still holds it, leave it alone
+		if !conv.mu.TryLock() {
+			continue
+		}
+		if !conv.done {
+			b.endConversation(conv, metrics.ConversationInterrupted)
+			b.reply(ctx, b.discord.Session(), conv,
+				":construction: SwinceBot is restarting, so this submission was cancelled. Use `/swince` to start over in a minute.")
+		}
+		conv.mu.Unlock()
+	}
+	if len(conversations) > 0 {
+		logger.InfoContext(ctx, "Interrupted swince conversations", "conversations", len(conversations))
+	}
+}
@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/bwmarrin/discordgo"
+)
+
+// setupPermissions are needed to see /setup: configuring the bot is left to
+// whoever manages the server.
+const setupPermissions = int64(discordgo.PermissionManageServer)
+
+// setupCommand is registered globally so servers which aren't configured yet
+// can onboard themselves
+func setupCommand() *discordgo.ApplicationCommand {
+	perms, dm := setupPermissions, false
+
+	rulesets := ruleset.Builtins()
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(rulesets))
+	for _, name := range rulesets {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: name})
+	}
+
+	textChannels := []discordgo.ChannelType{discordgo.ChannelTypeGuildText}
+	return &discordgo.ApplicationCommand{
+		Name:                     "setup",
+		Description:              "Configure SwinceBot on this server",
+		DefaultMemberPermissions: &perms,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				Name:         "channel",
+				Description:  "Where swinces get reposted for peer verification",
+				ChannelTypes: textChannels,
+				Required:     true,
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				Name:         "announcements",
+				Description:  "Where approved swinces get announced",
+				ChannelTypes: textChannels,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionRole,
+				Name:        "admin_role",
+				Description: "Members with this role may review submissions",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "timezone",
+				Description: "IANA timezone used to display dates (ex: America/Toronto)",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "ruleset",
+				Description: "How swinces are scored (only applies before the first season starts)",
+				Choices:     choices,
+			},
+		},
+	}
+}
+
+func (b *Bot) handleSetupCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildID, err := strconv.ParseUint(i.GuildID, 10, 64)
+	if err != nil {
+		b.respondEphemeral(ctx, s, i, "SwinceBot can only be set up from a server.")
+		return
+	}
+
+	guild, err := b.db.GetGuild(ctx, guildID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.ErrorContext(ctx, "Failed to get guild configuration", logging.ErrKey, err, "guild_id", guildID)
+		b.respondEphemeral(ctx, s, i, ":warning: Something went wrong, please try again later.")
+		return
+	}
+	if i.Member.Permissions&setupPermissions == 0 && !(exists && isAdmin(guild, i)) {
+		b.respondEphemeral(ctx, s, i, ":lock: Only server managers can set up SwinceBot.")
+		return
+	}
+
+	// Options which aren't given keep their current value
+	cfg := database.UpsertGuildParams{
+		GuildID:               guildID,
+		AnnouncementChannelID: guild.AnnouncementChannelID,
+		Admins:                guild.Admins,
+		AdminRoleID:           guild.AdminRoleID,
+		Timezone:              guild.Timezone,
+		Ruleset:               guild.Ruleset,
+	}
+	if !exists {
+		cfg.Timezone, cfg.Ruleset = "UTC", ruleset.Default
+	}
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "channel":
+			cfg.ChannelID, err = strconv.ParseUint(opt.Value.(string), 10, 64)
+		case "announcements":
+			var id uint64
+			id, err = strconv.ParseUint(opt.Value.(string), 10, 64)
+			cfg.AnnouncementChannelID = &id
+		case "admin_role":
+			var id uint64
+			id, err = strconv.ParseUint(opt.Value.(string), 10, 64)
+			cfg.AdminRoleID = &id
+		case "timezone":
+			cfg.Timezone = opt.StringValue()
+			if _, tzErr := time.LoadLocation(cfg.Timezone); tzErr != nil {
+				b.respondEphemeral(ctx, s, i, fmt.Sprintf("Unknown timezone `%s`, use an IANA name such as `America/Toronto`.", cfg.Timezone))
+				return
+			}
+		case "ruleset":
+			cfg.Ruleset = opt.StringValue()
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Malformed setup option", logging.ErrKey, err, "option", opt.Name)
+			b.respondEphemeral(ctx, s, i, ":warning: Something went wrong, please try again later.")
+			return
+		}
+	}
+
+	if err := b.db.UpsertGuild(ctx, cfg); err != nil {
+		logger.ErrorContext(ctx, "Failed to save guild configuration", logging.ErrKey, err, "guild_id", guildID)
+		b.respondEphemeral(ctx, s, i, ":warning: Unable to save the configuration, please try again later.")
+		return
+	}
+	logger.InfoContext(ctx, "Guild configured", "guild_id", guildID, "by", i.Member.User.ID)
+
+	b.respondEphemeral(ctx, s, i, setupSummary(cfg))
+
+	if exists {
+		return
+	}
+	if err := b.registerGuildCommands(ctx, guildID); err != nil {
+		logger.ErrorContext(ctx, "Failed to register commands on new guild", logging.ErrKey, err, "guild_id", guildID)
+		return
+	}
+	b.announce(ctx, cfg.AnnouncementChannelID, "", ":wave: SwinceBot is ready! Use `/swince` to submit your swinces.")
+}
+
+// setupSummary tells admins what the guild's configuration now looks like
+func setupSummary(cfg database.UpsertGuildParams) string {
+	var sb strings.Builder
+	sb.WriteString(":white_check_mark: SwinceBot is set up:\n")
+	sb.WriteString(fmt.Sprintf("- Swinces are reposted in <#%d>\n", cfg.ChannelID))
+	if cfg.AnnouncementChannelID != nil {
+		sb.WriteString(fmt.Sprintf("- Approved swinces are announced in <#%d>\n", *cfg.AnnouncementChannelID))
+	}
+	if cfg.AdminRoleID != nil {
+		sb.WriteString(fmt.Sprintf("- Members with <@&%d> may review submissions\n", *cfg.AdminRoleID))
+	}
+	sb.WriteString(fmt.Sprintf("- Dates are shown in `%s`\n", cfg.Timezone))
+	sb.WriteString(fmt.Sprintf("- Swinces are scored with the `%s` ruleset\n", cfg.Ruleset))
+	return sb.String()
+}
+
+// announce queues a post to a guild's announcement channel, if it has one.
+// Announcements sharing a non-empty key are only posted once.
+func (b *Bot) announce(ctx context.Context, channelID *uint64, key, content string) {
+	if channelID == nil {
+		return
+	}
+	err := b.outbox.Enqueue(ctx, discord.Message{
+		ChannelID: strconv.FormatUint(*channelID, 10),
+		Key:       key,
+		Send:      &discordgo.MessageSend{Content: content},
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to post announcement", logging.ErrKey, err, "channel_id", *channelID)
+	}
+}
@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	errProofMissing  = errors.New("no proof provided")
+	errProofNotVideo = errors.New("proof is not a video")
+	errProofTooLarge = errors.New("proof exceeds the maximum size")
+	errProofLink     = errors.New("proof link could not be reached")
+
+	errForbiddenAddress = errors.New("address not allowed")
+)
+
+// newLinkClient returns a client for the links users hand to the bot. Only
+// http(s) is spoken, and only public addresses are dialed: the check runs
+// after DNS resolution and on every redirect, so a link can't point the bot
+// at itself or at the network it runs in (metadata endpoints, ...).
+func newLinkClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialPublic}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // a proxy would dial on the bot's behalf
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: webOnly{transport},
+	}
+}
+
+// webOnly refuses requests, redirects included, to anything but http(s)
+type webOnly struct {
+	next http.RoundTripper
+}
+
+func (t webOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s scheme", errForbiddenAddress, req.URL.Scheme)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// dialPublic is a net.Dialer Control refusing to connect to loopback,
+// private, link-local, multicast and unspecified addresses
+func dialPublic(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// proof is a video (uploaded or linked) backing a swince submission
+type proof struct {
+	URL         string
+	Filename    string
+	ContentType string
+	Size        int64  // -1 until downloaded when the link didn't tell
+	Data        []byte // the video itself, once downloaded
+}
+
+// proofFromMessage extracts the proof from a DM. Attachments take precedence
+// over links found in the message content.
+func (b *Bot) proofFromMessage(ctx context.Context, m *discordgo.Message) (*proof, error) {
+	var p *proof
+	if len(m.Attachments) > 0 {
+		a := m.Attachments[0]
+		p = &proof{
+			URL:         a.URL,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        int64(a.Size),
+		}
+	} else {
+		link := findLink(m.Content)
+		if link == "" {
+			return nil, errProofMissing
+		}
+		var err error
+		if p, err = b.probeLink(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.validateProof(p); err != nil {
+		return nil, err
+	}
+	if err := b.downloadProof(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (b *Bot) validateProof(p *proof) error {
+	mediaType, _, err := mime.ParseMediaType(p.ContentType)
+	if err != nil || !strings.HasPrefix(mediaType, "video/") {
+		return errProofNotVideo
+	}
+	if p.Size > b.cfg.ProofMaxSize {
+		return errProofTooLarge
+	}
+	return nil
+}
+
+// probeLink issues a HEAD request so the MIME type and size of a link can be
+// checked without downloading the whole video.
+func (b *Bot) probeLink(ctx context.Context, link string) (*proof, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errProofLink, err)
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errProofLink, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: %s", errProofLink, resp.Status)
+	}
+
+	return &proof{
+		URL:         link,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}, nil
+}
+
+// downloadProof fetches the video so it can be uploaded again: Discord's CDN
+// links expire, and other links may go away. Sizes the link didn't tell are
+// enforced while reading.
+func (b *Bot) downloadProof(ctx context.Context, p *proof) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errProofLink, err)
+	}
+	resp, err := b.downloads.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errProofLink, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: %s", errProofLink, resp.Status)
+	}
+	// What gets uploaded is what the GET returned, whatever HEAD said
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "video/") {
+		return errProofNotVideo
+	}
+	p.ContentType = resp.Header.Get("Content-Type")
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, b.cfg.ProofMaxSize+1))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errProofLink, err)
+	}
+	if int64(len(data)) > b.cfg.ProofMaxSize {
+		return errProofTooLarge
+	}
+
+	p.Data, p.Size = data, int64(len(data))
+	if p.Filename == "" {
+		p.Filename = proofFilename(p)
+	}
+	return nil
+}
+
+// proofFilename names a linked video after its URL, or its type when the
+// URL doesn't end with a file name
+func proofFilename(p *proof) string {
+	if u, err := url.Parse(p.URL); err == nil && path.Ext(u.Path) != "" {
+		return path.Base(u.Path)
+	}
+	mediaType, _, _ := mime.ParseMediaType(p.ContentType)
+	exts, _ := mime.ExtensionsByType(mediaType)
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	switch {
+	case slices.Contains(exts, "."+subtype):
+		return "proof." + subtype
+	case len(exts) > 0:
+		return "proof" + exts[0]
+	}
+	return "proof.mp4"
+}
+
+// findLink returns the first http(s) URL in a message
+func findLink(content string) string {
+	for _, field := range strings.Fields(content) {
+		field = strings.Trim(field, "<>")
+		u, err := url.Parse(field)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if u.Scheme == "http" || u.Scheme == "https" {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// proofErrorMessage turns a proof validation error into something a user can act on
+func proofErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, errProofMissing):
+		return "I need a video to accept this swince"
+	case errors.Is(err, errProofNotVideo):
+		return "That doesn't look like a video"
+	case errors.Is(err, errProofTooLarge):
+		return "That video is too large"
+	case errors.Is(err, errProofLink):
+		return "I couldn't reach that link"
+	default:
+		return "That proof is invalid"
+	}
+}
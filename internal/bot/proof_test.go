@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDialPublic(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:1157", false},
+		{"[::1]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"224.0.0.1:80", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := dialPublic("tcp", tt.address, nil)
+			if tt.allowed && err != nil {
+				t.Errorf("dialPublic refused %s: %v", tt.address, err)
+			} else if !tt.allowed && !errors.Is(err, errForbiddenAddress) {
+				t.Errorf("dialPublic(%s) returned %v, want errForbiddenAddress", tt.address, err)
+			}
+		})
+	}
+}
+
+func TestLinkClientRefusesInternalLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+	}))
+	defer srv.Close()
+
+	client := newLinkClient(5 * time.Second)
+	for _, link := range []string{srv.URL, "file:///etc/passwd"} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, errForbiddenAddress) {
+			t.Errorf("HEAD %s returned %v, want errForbiddenAddress", link, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+// reminderInterval is how often nominations are checked for due reminders
+const reminderInterval = time.Minute
+
+// RemindNominations pings nominees in their server's channel when the
+// deadline of a nomination they owe gets close, until ctx is done. Reminders
+// come due while the gateway is down are sent as soon as it's back, unless
+// the deadline passed in the meantime.
+func (b *Bot) RemindNominations(ctx context.Context) {
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+	for {
+		if b.discord.Connected() {
+			if err := b.sendReminders(ctx, time.Now()); err != nil {
+				logger.ErrorContext(ctx, "Failed to send nomination reminders", logging.ErrKey, err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-b.reconnected:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notifyReconnected wakes RemindNominations up once the gateway is back
+func (b *Bot) notifyReconnected() {
+	select {
+	case b.reconnected <- struct{}{}:
+	default: // already notified
+	}
+}
+
+func (b *Bot) handleGatewayResumed(_ *discordgo.Session, _ *discordgo.Resumed) {
+	b.notifyReconnected()
+}
+
+// sendReminders queues the reminders due at now. Each reminder is keyed by
+// its nomination so it only goes out once, even across restarts.
+func (b *Bot) sendReminders(ctx context.Context, now time.Time) error {
+	due, err := b.db.ListDueReminders(ctx, database.ListDueRemindersParams{
+		Time:   now.Add(-b.cfg.NominationDeadline),
+		Time_2: now.Add(b.cfg.NominationReminder - b.cfg.NominationDeadline),
+	})
+	if err != nil {
+		return fmt.Errorf("listing due reminders: %w", err)
+	}
+
+	for _, n := range due {
+		deadline := n.Time.Add(b.cfg.NominationDeadline)
+		err := b.outbox.Enqueue(ctx, discord.Message{
+			ChannelID: strconv.FormatUint(n.ChannelID, 10),
+			Key:       "reminder:" + n.SwinceID,
+			Send: &discordgo.MessageSend{
+				Content: fmt.Sprintf(":alarm_clock: <@%d>, you have until <t:%d:f> (<t:%d:R>) to answer <@%d>'s nomination!",
+					*n.NomineeID, deadline.Unix(), deadline.Unix(), n.ParticipantID),
+				AllowedMentions: &discordgo.MessageAllowedMentions{
+					Users: []string{strconv.FormatUint(*n.NomineeID, 10)},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("queueing reminder of nomination %s: %w", n.SwinceID, err)
+		}
+	}
+	if len(due) > 0 {
+		logger.InfoContext(ctx, "Queued nomination reminders", "count", len(due))
+	}
+	return nil
+}
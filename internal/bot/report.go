@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageMaxLen is the longest message content Discord accepts
+const messageMaxLen = 2000
+
+var errNoReportTarget = errors.New("no channel or user to report errors to")
+
+// ReportError posts an error report to the configured channel, or DMs it to
+// the configured user. It's a logging.ReportFunc.
+func (b *Bot) ReportError(ctx context.Context, r logging.Report) error {
+	msg := discord.Message{Send: &discordgo.MessageSend{Content: formatReport(r)}}
+	switch {
+	case b.cfg.ReportChannelID != 0:
+		msg.ChannelID = strconv.FormatUint(b.cfg.ReportChannelID, 10)
+	case b.cfg.ReportUserID != 0:
+		msg.UserID = strconv.FormatUint(b.cfg.ReportUserID, 10)
+	default:
+		return errNoReportTarget
+	}
+	return b.outbox.Enqueue(ctx, msg)
+}
+
+// formatReport fits a report in a message, cutting the stack trace if needed
+func formatReport(r logging.Report) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf(":rotating_light: **%s** (`%s`)\n", r.Message, r.Fingerprint))
+	if r.Count > 1 {
+		sb.WriteString(fmt.Sprintf("Happened %d times since <t:%d:f>\n", r.Count, r.First.Unix()))
+	}
+	if r.Source != "" {
+		sb.WriteString(fmt.Sprintf("Logged at `%s`\n", r.Source))
+	}
+	if len(r.Attrs) > 0 {
+		sb.WriteString("```\n")
+		for _, a := range r.Attrs {
+			sb.WriteString(a.String() + "\n")
+		}
+		sb.WriteString("```\n")
+	}
+
+	content := sb.String()
+	if r.Trace != "" {
+		const fence = "```\n"
+		room := messageMaxLen - len(content) - 2*len(fence)
+		if room > len("…\n") {
+			content += fence + truncate(r.Trace, room) + fence
+		}
+	}
+	return truncate(content, messageMaxLen)
+}
+
+// truncate cuts s to at most n bytes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n-len("…")], "") + "…"
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxReviewEntries keeps review listings under Discord's message length limit
+const maxReviewEntries = 15
+
+func reviewCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "review",
+		Description: "Review flagged swince submissions (admins only)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "duplicates",
+				Description: "List submissions whose video was already submitted before",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "dismiss",
+				Description: "Clear the duplicate flag of a submission",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "event",
+						Description: "ID of the flagged event",
+						Required:    true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "disputes",
+				Description: "List swinces disputed by peers",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "resolve",
+				Description: "Settle a disputed swince",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "event",
+						Description: "ID of the disputed event",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "verdict",
+						Description: "Whether the swince counts",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "approve", Value: database.VerificationApproved},
+							{Name: "reject", Value: database.VerificationRejected},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// isOperator tells whether the user behind an interaction runs the bot.
+// Unlike guild admins, operators may change what affects every server.
+func (b *Bot) isOperator(i *discordgo.InteractionCreate) bool {
+	id, err := strconv.ParseUint(i.Member.User.ID, 10, 64)
+	return err == nil && slices.Contains(b.cfg.Operators, id)
+}
+
+// isAdmin reports whether the author of an interaction administers the guild,
+// either by having its admin role or by being listed as one of its admins
+func isAdmin(guild database.Guild, i *discordgo.InteractionCreate) bool {
+	if guild.AdminRoleID != nil && slices.Contains(i.Member.Roles, strconv.FormatUint(*guild.AdminRoleID, 10)) {
+		return true
+	}
+	id, err := strconv.ParseUint(i.Member.User.ID, 10, 64)
+	if err != nil {
+		return false
+	}
+	return guild.IsAdmin(id)
+}
+
+func (b *Bot) handleReviewCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guild, ok := b.interactionGuild(ctx, s, i)
+	if !ok {
+		return
+	}
+	if !isAdmin(guild, i) {
+		b.respondEphemeral(ctx, s, i, ":lock: Only admins can review submissions.")
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	var content string
+	switch sub.Name {
+	case "duplicates":
+		content = b.listDuplicates(ctx, guild)
+	case "dismiss":
+		content = b.dismissDuplicate(ctx, guild, sub.Options[0].StringValue())
+	case "disputes":
+		content = b.listDisputes(ctx, guild)
+	case "resolve":
+		content = b.resolveDispute(ctx, guild, sub.Options[0].StringValue(), sub.Options[1].StringValue())
+	}
+
+	b.respondEphemeral(ctx, s, i, content)
+}
+
+func (b *Bot) listDuplicates(ctx context.Context, guild database.Guild) string {
+	duplicates, err := b.db.ListSuspectedDuplicates(ctx, guild.GuildID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to list suspected duplicates", logging.ErrKey, err)
+		return ":warning: Unable to fetch suspected duplicates."
+	}
+	if len(duplicates) == 0 {
+		return ":white_check_mark: No suspected duplicates to review."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%d suspected duplicate(s)**\n", len(duplicates)))
+	for idx, d := range duplicates {
+		if idx == maxReviewEntries {
+			sb.WriteString(fmt.Sprintf("...and %d more\n", len(duplicates)-idx))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("- `%s` (%s): %s same video as %s\n",
+			d.EventID,
+			d.Time.In(guild.Location()).Format("2006-01-02"),
+			proofLink(guild, d.Proof),
+			proofLink(guild, d.OriginalProof),
+		))
+	}
+	return sb.String()
+}
+
+func (b *Bot) dismissDuplicate(ctx context.Context, guild database.Guild, eventID string) string {
+	n, err := b.db.MarkDuplicateReviewed(ctx, database.MarkDuplicateReviewedParams{
+		EventID: eventID,
+		GuildID: guild.GuildID,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to dismiss duplicate", logging.ErrKey, err, "event_id", eventID)
+		return ":warning: Unable to dismiss that submission."
+	}
+	if n == 0 {
+		return fmt.Sprintf("No archived submission with ID `%s`.", eventID)
+	}
+	return fmt.Sprintf(":white_check_mark: Submission `%s` was marked as reviewed.", eventID)
+}
+
+// archiveProof keeps a local copy of the proof and flags it when the exact
+// same video was already submitted for another event.
+func (b *Bot) archiveProof(ctx context.Context, guildID uint64, eventID string, p *proof) {
+	entry, err := b.cfg.Archiver.Store(bytes.NewReader(p.Data))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to archive proof", logging.ErrKey, err, "event_id", eventID)
+		return
+	}
+
+	var duplicateOf sql.NullString
+	original, err := b.db.GetArchiveByHash(ctx, database.GetArchiveByHashParams{
+		GuildID: guildID,
+		Sha256:  entry.SHA256,
+		EventID: eventID,
+	})
+	switch {
+	case err == nil:
+		duplicateOf = sql.NullString{String: original.EventID, Valid: true}
+		logger.WarnContext(ctx, "Suspected duplicate proof submitted",
+			"event_id", eventID,
+			"duplicate_of", original.EventID,
+			"sha256", entry.SHA256,
+		)
+	case !errors.Is(err, sql.ErrNoRows):
+		logger.ErrorContext(ctx, "Failed to look up archived proofs", logging.ErrKey, err, "event_id", eventID)
+		return
+	}
+
+	err = b.db.CreateArchive(ctx, database.CreateArchiveParams{
+		EventID:     eventID,
+		Sha256:      entry.SHA256,
+		Size:        entry.Size,
+		DuplicateOf: duplicateOf,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to record archived proof", logging.ErrKey, err, "event_id", eventID)
+		return
+	}
+
+	logger.InfoContext(ctx, "Archived proof", "event_id", eventID, "sha256", entry.SHA256, "size", entry.Size)
+}
+
+// proofLink points to the message where a proof was reposted
+func proofLink(guild database.Guild, messageID sql.NullInt64) string {
+	if !messageID.Valid {
+		return "(unknown proof)"
+	}
+	return fmt.Sprintf("https://discord.com/channels/%d/%d/%d", guild.GuildID, guild.ChannelID, messageID.Int64)
+}
+
+func (b *Bot) respondEphemeral(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to respond to interaction", logging.ErrKey, err)
+	}
+}
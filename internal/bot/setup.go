@@ -2,32 +2,89 @@ package bot
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ChausseBenjamin/swincebot/internal/archive"
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
+	"github.com/ChausseBenjamin/swincebot/internal/database"
 	"github.com/ChausseBenjamin/swincebot/internal/discord"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/ChausseBenjamin/swincebot/internal/tracing"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
 	"github.com/bwmarrin/discordgo"
 )
 
-type CommandHandler func(*discordgo.Session, *discordgo.InteractionCreate)
+var logger = logging.Subsystem("bot")
+
+type CommandHandler func(context.Context, *discordgo.Session, *discordgo.InteractionCreate)
+
+// Config holds the settings the bot needs once it is up and running.
+// Settings specific to a server (channel, admins, ...) live in the Guilds table.
+type Config struct {
+	ConversationTimeout time.Duration
+	ProofMaxSize        int64             // bytes
+	VerificationQuorum  uint64            // approvals needed before a swince counts
+	NominationDeadline  time.Duration     // time a nominee has to answer a nomination
+	NominationReminder  time.Duration     // nominees get reminded this long before the deadline
+	Archiver            *archive.Archiver // nil when proofs aren't archived
+	Tokens              *auth.Issuer      // mints the API tokens handed out by /token
+	Operators           []uint64          // users running the bot, trusted on every server
+	ReportChannelID     uint64            // where errors get reported, 0 to DM them to ReportUserID
+	ReportUserID        uint64
+}
 
 type Bot struct {
 	discord         *discord.Client
-	serverID        uint64
-	channelID       uint64
+	outbox          *discord.Outbox
+	db              *database.ProtoDB
+	cfg             Config
+	http            *http.Client
+	downloads       *http.Client // videos take longer than API calls
 	commandHandlers map[string]CommandHandler
+	buttonHandlers  map[string]CommandHandler // keyed by custom ID prefix
+	events          *feed
+
+	convMu        sync.Mutex
+	conversations map[string]*conversation // keyed by submitter user ID
+
+	registerMu   sync.Mutex
+	unregistered map[uint64]error // guilds whose commands couldn't be registered
+
+	reconnected chan struct{} // signaled when the gateway comes back
+
+	work inflight
 }
 
-func NewBot(ctx context.Context, discordClient *discord.Client, serverID, channelID uint64) (*Bot, error) {
+func NewBot(ctx context.Context, discordClient *discord.Client, db *database.ProtoDB, cfg Config) (*Bot, error) {
 	bot := &Bot{
-		discord:   discordClient,
-		serverID:  serverID,
-		channelID: channelID,
+		discord:       discordClient,
+		outbox:        discord.NewOutbox(discordClient, db),
+		db:            db,
+		cfg:           cfg,
+		http:          newLinkClient(10 * time.Second),
+		downloads:     newLinkClient(5 * time.Minute),
+		conversations: make(map[string]*conversation),
+		unregistered:  make(map[uint64]error),
+		events:        newFeed(),
+		reconnected:   make(chan struct{}, 1),
 	}
 
-	if err := bot.registerCommands(ctx); err != nil {
-		return nil, fmt.Errorf("registering commands: %w", err)
+	// Commands get registered once the gateway is up, which may take a while
+	guilds, err := db.ListGuilds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing guilds: %w", err)
+	}
+	for _, guild := range guilds {
+		bot.unregistered[guild.GuildID] = errNotConnected
 	}
 
 	bot.registerHandlers()
@@ -35,29 +92,72 @@ func NewBot(ctx context.Context, discordClient *discord.Client, serverID, channe
 	return bot, nil
 }
 
-func (b *Bot) registerCommands(ctx context.Context) error {
+var errNotConnected = errors.New("not connected to discord yet")
+
+// handleGatewayReady registers the slash commands at the start of every
+// gateway session. Guilds registered by a previous session are skipped since
+// Discord keeps their commands. Reminders missed while disconnected follow.
+func (b *Bot) handleGatewayReady(s *discordgo.Session, r *discordgo.Ready) {
+	ctx, span := tracing.Start(context.Background(), tracing.KindInternal, "register commands")
+	defer span.End()
+
+	if _, err := s.ApplicationCommandCreate(r.User.ID, "", setupCommand(), discordgo.WithContext(ctx)); err != nil {
+		span.RecordError(err)
+		logger.ErrorContext(ctx, "Failed to register slash command", logging.ErrKey, err, "command", "setup")
+	} else {
+		logger.InfoContext(ctx, "Registered slash command", "command", "setup")
+	}
+
+	b.registerMu.Lock()
+	pending := make([]uint64, 0, len(b.unregistered))
+	for guildID := range b.unregistered {
+		pending = append(pending, guildID)
+	}
+	b.registerMu.Unlock()
+
+	for _, guildID := range pending {
+		if err := b.registerGuildCommands(ctx, guildID); err != nil {
+			span.RecordError(err)
+			logger.ErrorContext(ctx, "Failed to register guild commands", logging.ErrKey, err, "guild_id", guildID)
+		}
+	}
+
+	b.notifyReconnected()
+}
+
+// registerGuildCommands creates the slash commands of a guild, remembering
+// failures so readiness checks report them until registration succeeds
+func (b *Bot) registerGuildCommands(ctx context.Context, guildID uint64) (err error) {
+	defer func() {
+		b.registerMu.Lock()
+		defer b.registerMu.Unlock()
+		if err != nil {
+			b.unregistered[guildID] = err
+		} else {
+			delete(b.unregistered, guildID)
+		}
+	}()
+
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "swince",
 			Description: "Submit a swince challenge",
 			Options: []*discordgo.ApplicationCommandOption{
-				{
-					Type:        discordgo.ApplicationCommandOptionUser,
-					Name:        "participants",
-					Description: "Users who participated in the challenge",
-					Required:    false,
-				},
+				swinceParticipantsArg(),
 			},
 		},
+		reviewCommand(),
+		tokenCommand(),
+		debugCommand(),
 	}
 
 	session := b.discord.Session()
 	for _, cmd := range commands {
-		_, err := session.ApplicationCommandCreate(session.State.User.ID, strconv.FormatUint(b.serverID, 10), cmd)
+		_, err := session.ApplicationCommandCreate(session.State.User.ID, strconv.FormatUint(guildID, 10), cmd, discordgo.WithContext(ctx))
 		if err != nil {
-			return fmt.Errorf("creating application command %s: %w", cmd.Name, err)
+			return fmt.Errorf("creating application command %s on guild %d: %w", cmd.Name, guildID, err)
 		}
-		slog.InfoContext(ctx, "Registered slash command", "command", cmd.Name)
+		logger.InfoContext(ctx, "Registered slash command", "command", cmd.Name, "guild_id", guildID)
 	}
 
 	return nil
@@ -67,23 +167,101 @@ func (b *Bot) registerHandlers() {
 	// Define command handlers map
 	b.commandHandlers = map[string]CommandHandler{
 		"swince": b.handleSwinceCommand,
+		"review": b.handleReviewCommand,
+		"token":  b.handleTokenCommand,
+		"setup":  b.handleSetupCommand,
+		"debug":  b.handleDebugCommand,
 		// Future commands can be added here:
 		// "leaderboard": b.handleLeaderboardCommand,
 		// "scores": b.handleScoresCommand,
 	}
+	b.buttonHandlers = map[string]CommandHandler{
+		verifyPrefix: b.handleVerifyButton,
+	}
 
 	session := b.discord.Session()
+	session.AddHandler(b.handleGatewayReady)
+	session.AddHandler(b.handleGatewayResumed)
 	session.AddHandler(b.handleInteraction)
+	session.AddHandler(b.handleDirectMessage)
 }
 
 func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	commandName := i.ApplicationCommandData().Name
+	ctx := context.WithValue(context.Background(), util.ReqIDKey, i.ID)
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		commandName := i.ApplicationCommandData().Name
+
+		if handler, exists := b.commandHandlers[commandName]; exists {
+			b.dispatch(ctx, "command", commandName, handler, s, i)
+		} else {
+			logger.WarnContext(ctx, "Unknown command received", "command", commandName)
+		}
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		prefix, _, _ := strings.Cut(customID, ":")
+
+		if handler, exists := b.buttonHandlers[prefix]; exists {
+			b.dispatch(ctx, "button", prefix, handler, s, i)
+		} else {
+			logger.WarnContext(ctx, "Unknown button pressed", "custom_id", customID)
+		}
+	}
+}
+
+// dispatch runs an interaction handler in its own span, the root of the
+// interaction's trace. Interactions arriving during shutdown are turned down.
+func (b *Bot) dispatch(ctx context.Context, kind, name string, handler CommandHandler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !b.work.begin() {
+		b.respondEphemeral(ctx, s, i, ":construction: SwinceBot is restarting, please try again in a minute.")
+		return
+	}
+	defer b.work.end()
+	defer metrics.Interactions.Since(time.Now(), kind, name)
+
+	ctx, span := tracing.Start(ctx, tracing.KindServer, kind+" "+name,
+		slog.String("discord.interaction_id", i.ID),
+		slog.String("discord.guild_id", i.GuildID),
+	)
+	defer span.End()
 
-	if handler, exists := b.commandHandlers[commandName]; exists {
-		handler(s, i)
+	handler(ctx, s, i)
+}
+
+// interactionGuild fetches the configuration of the server an interaction comes
+// from. Users of a server which isn't configured are told so.
+func (b *Bot) interactionGuild(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) (database.Guild, bool) {
+	guildID, err := strconv.ParseUint(i.GuildID, 10, 64)
+	if err == nil {
+		var guild database.Guild
+		guild, err = b.db.GetGuild(ctx, guildID)
+		if err == nil {
+			return guild, true
+		}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		b.respondEphemeral(ctx, s, i, ":construction: SwinceBot isn't set up on this server yet, a server manager needs to run `/setup`.")
 	} else {
-		slog.Warn("Unknown command received", "command", commandName)
+		logger.ErrorContext(ctx, "Failed to get guild configuration", logging.ErrKey, err, "guild_id", i.GuildID)
+		b.respondEphemeral(ctx, s, i, ":warning: Something went wrong, please try again later.")
 	}
+	return database.Guild{}, false
+}
+
+// Ready reports why the bot can't serve its users, nil when it can
+func (b *Bot) Ready(ctx context.Context) error {
+	if !b.discord.Connected() {
+		return errors.New("discord gateway disconnected")
+	}
+
+	b.registerMu.Lock()
+	defer b.registerMu.Unlock()
+	for guildID, err := range b.unregistered {
+		return fmt.Errorf("commands of guild %d aren't registered: %w", guildID, err)
+	}
+	return nil
 }
 
 func (b *Bot) Close() error {
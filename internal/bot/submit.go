@@ -1,10 +1,21 @@
 package bot
 
 import (
-	"log/slog"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
 	"github.com/ChausseBenjamin/swincebot/internal/logging"
 	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
 )
 
 func swinceParticipantsArg() *discordgo.ApplicationCommandOption {
@@ -16,10 +27,37 @@ func swinceParticipantsArg() *discordgo.ApplicationCommandOption {
 	}
 }
 
-func (b *Bot) handleSwinceCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (b *Bot) handleSwinceCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 	userID := i.Member.User.ID
 
-	slog.Info("Swince command received", "user_id", userID)
+	logger.InfoContext(ctx, "Swince command received", "user_id", userID, "guild_id", i.GuildID)
+
+	guild, ok := b.interactionGuild(ctx, s, i)
+	if !ok {
+		return
+	}
+
+	// Pre-fill participants when the submitter already named who swinced
+	var seed []uint64
+	data := i.ApplicationCommandData()
+	for _, opt := range data.Options {
+		if opt.Name != swinceParticipantsArg().Name {
+			continue
+		}
+		id := opt.Value.(string)
+		if data.Resolved != nil && data.Resolved.Users[id] != nil && data.Resolved.Users[id].Bot {
+			continue
+		}
+		if parsed, err := strconv.ParseUint(id, 10, 64); err == nil && !slices.Contains(seed, parsed) {
+			seed = append(seed, parsed)
+		}
+	}
+	if len(seed) > 0 {
+		if err := checkParticipants(seed, nil); err != nil {
+			b.respondEphemeral(ctx, s, i, fmt.Sprintf(":no_entry: %v", err))
+			return
+		}
+	}
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -28,9 +66,233 @@ func (b *Bot) handleSwinceCommand(s *discordgo.Session, i *discordgo.Interaction
 
 			Flags: discordgo.MessageFlagsEphemeral,
 		},
+	}, discordgo.WithContext(ctx))
+
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to respond to swince command", logging.ErrKey, err, "user_id", userID)
+		return
+	}
+
+	if err := b.startConversation(ctx, s, guild.GuildID, userID, seed); err != nil {
+		logger.ErrorContext(ctx, "Failed to start swince conversation", logging.ErrKey, err, "user_id", userID)
+	}
+}
+
+// ErrInvalidSubmission is returned by Submit when the submission itself is at fault
+var ErrInvalidSubmission = errors.New("invalid submission")
+
+// Submission is a swince submitted without going through a DM conversation
+type Submission struct {
+	GuildID      uint64 // server the swince gets posted on
+	SubmitterID  uint64
+	Participants []uint64
+	Nominees     map[uint64]*uint64 // keyed by participant, nil when swincing for no-one
+	ProofURL     string
+}
+
+// Submit checks a submission with the same rules as DM conversations, then
+// posts and records it. It returns the ID of the new event.
+func (b *Bot) Submit(ctx context.Context, sub Submission) (string, error) {
+	if err := checkParticipants(sub.Participants, sub.Nominees); err != nil {
+		return "", err
+	}
+	if _, err := b.db.GetGuild(ctx, sub.GuildID); errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w: guild %d isn't configured", ErrInvalidSubmission, sub.GuildID)
+	} else if err != nil {
+		return "", fmt.Errorf("getting guild: %w", err)
+	}
+
+	conv := &conversation{
+		guildID:  sub.GuildID,
+		userID:   strconv.FormatUint(sub.SubmitterID, 10),
+		nominees: make(map[uint64]*uint64, len(sub.Participants)),
+	}
+	for _, participant := range sub.Participants {
+		conv.participants = append(conv.participants, participant)
+		conv.nominees[participant] = sub.Nominees[participant]
+	}
+
+	p, err := b.probeLink(ctx, sub.ProofURL)
+	if err == nil {
+		err = b.validateProof(p)
+	}
+	if err == nil {
+		err = b.downloadProof(ctx, p)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSubmission, proofErrorMessage(err))
+	}
+
+	return b.submit(ctx, b.discord.Session(), conv, p)
+}
+
+// IsMember reports whether a user belongs to a guild
+func (b *Bot) IsMember(ctx context.Context, guildID, userID uint64) (bool, error) {
+	return b.discord.IsMember(ctx, guildID, userID)
+}
+
+// checkParticipants applies the rules every submission follows, whichever
+// way it comes in. Nominees may be nil when they aren't known yet.
+func checkParticipants(participants []uint64, nominees map[uint64]*uint64) error {
+	if len(participants) == 0 {
+		return fmt.Errorf("%w: no participants", ErrInvalidSubmission)
+	}
+	seen := make(map[uint64]bool, len(participants))
+	for _, participant := range participants {
+		if seen[participant] {
+			return fmt.Errorf("%w: <@%d> is listed more than once", ErrInvalidSubmission, participant)
+		}
+		seen[participant] = true
+		if nominee := nominees[participant]; nominee != nil && *nominee == participant {
+			return fmt.Errorf("%w: <@%d> nominated themselves", ErrInvalidSubmission, participant)
+		}
+	}
+	return nil
+}
+
+// submit reposts the proof on the swince channel and records the event. If the
+// event can't be recorded, the reposted message is removed so both stay in sync.
+func (b *Bot) submit(ctx context.Context, s *discordgo.Session, conv *conversation, p *proof) (string, error) {
+	if err := checkParticipants(conv.participants, conv.nominees); err != nil {
+		return "", err
+	}
+	eventID := uuid.NewString()
+
+	guild, err := b.db.GetGuild(ctx, conv.guildID)
+	if err != nil {
+		return "", fmt.Errorf("getting guild: %w", err)
+	}
+
+	// The outbox posts the proof even if the caller gives up (gRPC client gone,
+	// conversation timed out): from there on, the event must be recorded or
+	// the proof cleaned up regardless
+	ctx = context.WithoutCancel(ctx)
+	msg, err := b.postProof(ctx, guild, conv, p, eventID)
+	if err != nil {
+		return "", fmt.Errorf("posting proof: %w", err)
+	}
+
+	proofID, err := strconv.ParseUint(msg.ID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("parsing proof message ID: %w", err)
+	}
+
+	if err := b.recordSwince(ctx, conv, eventID, proofID); err != nil {
+		if delErr := s.ChannelMessageDelete(msg.ChannelID, msg.ID, discordgo.WithContext(ctx)); delErr != nil {
+			logger.ErrorContext(ctx, "Failed to remove orphaned proof", logging.ErrKey, delErr, "message_id", msg.ID)
+		}
+		return "", fmt.Errorf("recording swince: %w", err)
+	}
+
+	logger.InfoContext(ctx, "Swince submitted",
+		"user_id", conv.userID,
+		"guild_id", conv.guildID,
+		"participants", len(conv.participants),
+		"proof", msg.ID,
+	)
+	b.events.publish(eventID)
+
+	if b.cfg.Archiver != nil && b.work.begin() {
+		go func() {
+			defer b.work.end()
+			b.archiveProof(ctx, conv.guildID, eventID, p)
+		}()
+	}
+	return eventID, nil
+}
+
+// postProof sends the proof to the guild's swince channel, tagging every
+// participant and nominee. Peers verify the swince using the buttons attached to it.
+func (b *Bot) postProof(ctx context.Context, guild database.Guild, conv *conversation, p *proof, eventID string) (*discordgo.Message, error) {
+	var (
+		content  strings.Builder
+		mentions []string
+	)
+
+	content.WriteString(fmt.Sprintf(":beer: **New swince submitted by <@%s>!**\n", conv.userID))
+	for _, participant := range conv.participants {
+		mentions = append(mentions, strconv.FormatUint(participant, 10))
+		if nominee := conv.nominees[participant]; nominee != nil {
+			mentions = append(mentions, strconv.FormatUint(*nominee, 10))
+			content.WriteString(fmt.Sprintf("- <@%d> nominates <@%d>\n", participant, *nominee))
+		} else {
+			content.WriteString(fmt.Sprintf("- <@%d> swinced for no-one\n", participant))
+		}
+	}
+
+	// Uploaded rather than linked so the proof outlives the link it came from
+	return b.outbox.Send(ctx, discord.Message{
+		ChannelID: strconv.FormatUint(guild.ChannelID, 10),
+		Key:       "proof:" + eventID,
+		Send: &discordgo.MessageSend{
+			Content: content.String(),
+			Files: []*discordgo.File{{
+				Name:        p.Filename,
+				ContentType: p.ContentType,
+				Reader:      bytes.NewReader(p.Data),
+			}},
+			Components: verificationButtons(eventID),
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				Users: mentions,
+			},
+		},
 	})
+}
+
+// recordSwince stores the event and its swinces. Each participant fulfills the
+// oldest nomination they still owed (if any).
+func (b *Bot) recordSwince(ctx context.Context, conv *conversation, eventID string, proofID uint64) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
 
+	q := b.db.WithTx(tx)
+
+	err = q.CreateEvent(ctx, database.CreateEventParams{
+		EventID: eventID,
+		GuildID: conv.guildID,
+		Time:    time.Now().UTC(),
+		Proof:   sql.NullInt64{Int64: int64(proofID), Valid: true},
+	})
 	if err != nil {
-		slog.Error("Failed to respond to swince command", logging.ErrKey, err, "user_id", userID)
+		return fmt.Errorf("creating event: %w", err)
+	}
+
+	for _, participant := range conv.participants {
+		swinceID := uuid.NewString()
+
+		err = q.CreateSwince(ctx, database.CreateSwinceParams{
+			EventID:       eventID,
+			GuildID:       conv.guildID,
+			SwinceID:      swinceID,
+			ParticipantID: participant,
+			NomineeID:     conv.nominees[participant],
+		})
+		if err != nil {
+			return fmt.Errorf("creating swince: %w", err)
+		}
+
+		nomination, err := q.GetOpenNomination(ctx, database.GetOpenNominationParams{
+			GuildID:   conv.guildID,
+			NomineeID: &participant,
+			EventID:   eventID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("getting open nomination: %w", err)
+		}
+
+		err = q.FulfillNomination(ctx, database.FulfillNominationParams{
+			FulfillmentID: sql.NullString{String: swinceID, Valid: true},
+			SwinceID:      nomination.SwinceID,
+		})
+		if err != nil {
+			return fmt.Errorf("fulfilling nomination: %w", err)
+		}
 	}
+
+	return tx.Commit()
 }
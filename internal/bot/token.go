@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+func tokenCommand() *discordgo.ApplicationCommand {
+	minDays := float64(1)
+	return &discordgo.ApplicationCommand{
+		Name:        "token",
+		Description: "Manage your API tokens",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "create",
+				Description: "Mint a token to use the bot's API",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "scope",
+						Description: "What the token may be used for",
+						Required:    true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "read scores and events", Value: string(auth.ScopeRead)},
+							{Name: "read and submit swinces as me", Value: string(auth.ScopeRead) + " " + string(auth.ScopeSubmit)},
+							{Name: "operate the bot (operators only)", Value: string(auth.ScopeAdmin)},
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "days",
+						Description: "How long the token stays valid (defaults to the maximum)",
+						MinValue:    &minDays,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "List your active tokens",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "revoke",
+				Description: "Revoke a token",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "id",
+						Description: "ID of the token (see /token list)",
+						Required:    true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (b *Bot) handleTokenCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	userID, err := strconv.ParseUint(i.Member.User.ID, 10, 64)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to parse user ID", logging.ErrKey, err, "user_id", i.Member.User.ID)
+		return
+	}
+
+	sub := i.ApplicationCommandData().Options[0]
+	var content string
+	switch sub.Name {
+	case "create":
+		var scopes []auth.Scope
+		var ttl time.Duration
+		for _, opt := range sub.Options {
+			switch opt.Name {
+			case "scope":
+				for _, scope := range strings.Fields(opt.StringValue()) {
+					scopes = append(scopes, auth.Scope(scope))
+				}
+			case "days":
+				ttl = time.Duration(opt.IntValue()) * 24 * time.Hour
+			}
+		}
+		// Admin tokens reach endpoints affecting every server
+		if slices.Contains(scopes, auth.ScopeAdmin) && !b.isOperator(i) {
+			b.respondEphemeral(ctx, s, i, ":lock: Only the bot's operators can create admin tokens.")
+			return
+		}
+		content = b.createToken(ctx, userID, scopes, ttl)
+	case "list":
+		content = b.listTokens(ctx, userID)
+	case "revoke":
+		content = b.revokeToken(ctx, userID, b.isOperator(i), sub.Options[0].StringValue())
+	}
+
+	b.respondEphemeral(ctx, s, i, content)
+}
+
+func (b *Bot) createToken(ctx context.Context, userID uint64, scopes []auth.Scope, ttl time.Duration) string {
+	token, claims, err := b.cfg.Tokens.Mint(ctx, userID, scopes, ttl)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to mint API token", logging.ErrKey, err, "user_id", userID)
+		return ":warning: Unable to create a token right now."
+	}
+
+	logger.InfoContext(ctx, "Minted API token", "user_id", userID, "token_id", claims.ID, "scope", claims.Scope)
+	return fmt.Sprintf(":key: Here is your token (`%s`, scope: %s), valid until <t:%d:f>. "+
+		"Keep it secret, it won't be shown again:\n```\n%s\n```",
+		claims.ID, claims.Scope, claims.ExpiresAt, token)
+}
+
+func (b *Bot) listTokens(ctx context.Context, userID uint64) string {
+	tokens, err := b.db.ListUserTokens(ctx, database.ListUserTokensParams{
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to list API tokens", logging.ErrKey, err, "user_id", userID)
+		return ":warning: Unable to fetch your tokens."
+	}
+	if len(tokens) == 0 {
+		return "You have no active tokens."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%d active token(s)**\n", len(tokens)))
+	for _, t := range tokens {
+		sb.WriteString(fmt.Sprintf("- `%s` (%s): expires <t:%d:R>\n", t.TokenID, t.Scopes, t.ExpiresAt.Unix()))
+	}
+	return sb.String()
+}
+
+// revokeToken lets users revoke their own tokens and operators revoke
+// anyone's. Tokens work on every server, so guild admins don't qualify.
+func (b *Bot) revokeToken(ctx context.Context, userID uint64, operator bool, tokenID string) string {
+	token, err := b.db.GetToken(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && token.UserID != userID && !operator) {
+		return fmt.Sprintf("No token of yours has the ID `%s`.", tokenID)
+	} else if err != nil {
+		logger.ErrorContext(ctx, "Failed to look up API token", logging.ErrKey, err, "token_id", tokenID)
+		return ":warning: Unable to revoke that token."
+	}
+
+	revoked, err := b.cfg.Tokens.Revoke(ctx, tokenID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to revoke API token", logging.ErrKey, err, "token_id", tokenID)
+		return ":warning: Unable to revoke that token."
+	}
+	if !revoked {
+		return fmt.Sprintf("Token `%s` was already revoked.", tokenID)
+	}
+
+	logger.InfoContext(ctx, "Revoked API token", "token_id", tokenID, "user_id", userID)
+	return fmt.Sprintf(":white_check_mark: Token `%s` was revoked.", tokenID)
+}
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	verifyPrefix  = "verify"
+	verifyApprove = "approve"
+	verifyDispute = "dispute"
+)
+
+// verificationButtons lets peers approve or dispute a reposted proof.
+// Custom IDs look like "verify:approve:<event_id>".
+func verificationButtons(eventID string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: strings.Join([]string{verifyPrefix, verifyApprove, eventID}, ":"),
+				},
+				discordgo.Button{
+					Label:    "Dispute",
+					Style:    discordgo.DangerButton,
+					CustomID: strings.Join([]string{verifyPrefix, verifyDispute, eventID}, ":"),
+				},
+			},
+		},
+	}
+}
+
+func (b *Bot) handleVerifyButton(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	voter := i.Member.User.ID
+
+	guild, ok := b.interactionGuild(ctx, s, i)
+	if !ok {
+		return
+	}
+
+	parts := strings.SplitN(i.MessageComponentData().CustomID, ":", 3)
+	if len(parts) != 3 {
+		logger.WarnContext(ctx, "Malformed verification button", "custom_id", i.MessageComponentData().CustomID)
+		return
+	}
+	approve, eventID := parts[1] == verifyApprove, parts[2]
+
+	content, err := b.castVote(ctx, guild, eventID, voter, approve)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to record verification vote",
+			logging.ErrKey, err,
+			"event_id", eventID,
+			"user_id", voter,
+		)
+		content = ":warning: Unable to record your vote, please try again later."
+	}
+
+	b.respondEphemeral(ctx, s, i, content)
+}
+
+// castVote records a peer's vote and moves the event to its next verification
+// state. The returned string is meant for the voter.
+func (b *Bot) castVote(ctx context.Context, guild database.Guild, eventID, voter string, approve bool) (string, error) {
+	voterID, err := strconv.ParseUint(voter, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("parsing voter ID: %w", err)
+	}
+
+	event, err := b.db.GetEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && event.GuildID != guild.GuildID) {
+		return "This swince no longer exists.", nil
+	} else if err != nil {
+		return "", fmt.Errorf("getting event: %w", err)
+	}
+	if event.Verification != database.VerificationPending {
+		return fmt.Sprintf("Voting is closed, this swince is already **%s**.", event.Verification), nil
+	}
+
+	participants, err := b.db.GetEventParticipants(ctx, eventID)
+	if err != nil {
+		return "", fmt.Errorf("getting event participants: %w", err)
+	}
+	if slices.Contains(participants, voterID) {
+		return "You can't vote on your own swince :wink:", nil
+	}
+
+	var approval int64
+	if approve {
+		approval = 1
+	}
+	err = b.db.CastVote(ctx, database.CastVoteParams{
+		EventID: eventID,
+		VoterID: voterID,
+		Approve: approval,
+	})
+	if err != nil {
+		return "", fmt.Errorf("casting vote: %w", err)
+	}
+
+	votes, err := b.db.CountVotes(ctx, eventID)
+	if err != nil {
+		return "", fmt.Errorf("counting votes: %w", err)
+	}
+
+	state := database.VerificationPending
+	switch {
+	case votes.Disputes > 0:
+		state = database.VerificationDisputed
+	case votes.Approvals >= int64(b.cfg.VerificationQuorum):
+		state = database.VerificationApproved
+	}
+	if state == database.VerificationPending {
+		return fmt.Sprintf("Vote recorded (%d/%d approvals).", votes.Approvals, b.cfg.VerificationQuorum), nil
+	}
+
+	if err := b.setVerification(ctx, guild, event, state); err != nil {
+		return "", err
+	}
+	if state == database.VerificationDisputed {
+		return "Vote recorded, this swince was sent to the admins for review.", nil
+	}
+	return "Vote recorded, this swince is now approved :tada:", nil
+}
+
+// setVerification stores the new state and removes the voting buttons from
+// the proof once peers can no longer vote on it. Nominations answered by a
+// rejected swince are open again.
+func (b *Bot) setVerification(ctx context.Context, guild database.Guild, event database.Event, state string) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	q := b.db.WithTx(tx)
+	err = q.SetEventVerification(ctx, database.SetEventVerificationParams{
+		Verification: state,
+		EventID:      event.EventID,
+	})
+	if err != nil {
+		return fmt.Errorf("setting event verification: %w", err)
+	}
+	if state == database.VerificationRejected {
+		if err := q.ReopenNominations(ctx, event.EventID); err != nil {
+			return fmt.Errorf("reopening nominations: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logger.InfoContext(ctx, "Swince verification changed", "event_id", event.EventID, "verification", state)
+
+	if state == database.VerificationApproved {
+		b.announceApproval(ctx, guild, event.EventID)
+	}
+
+	if !event.Proof.Valid {
+		return nil
+	}
+	edit := discordgo.NewMessageEdit(
+		strconv.FormatUint(guild.ChannelID, 10),
+		strconv.FormatInt(event.Proof.Int64, 10),
+	)
+	edit.Components = &[]discordgo.MessageComponent{}
+	if _, err := b.discord.Session().ChannelMessageEditComplex(edit, discordgo.WithContext(ctx)); err != nil {
+		logger.WarnContext(ctx, "Failed to remove verification buttons", logging.ErrKey, err, "event_id", event.EventID)
+	}
+	return nil
+}
+
+func (b *Bot) listDisputes(ctx context.Context, guild database.Guild) string {
+	disputes, err := b.db.ListDisputedEvents(ctx, guild.GuildID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to list disputed events", logging.ErrKey, err)
+		return ":warning: Unable to fetch disputed swinces."
+	}
+	if len(disputes) == 0 {
+		return ":white_check_mark: No disputed swinces to review."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%d disputed swince(s)**\n", len(disputes)))
+	for idx, d := range disputes {
+		if idx == maxReviewEntries {
+			sb.WriteString(fmt.Sprintf("...and %d more\n", len(disputes)-idx))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("- `%s` (%s): %s, %d dispute(s)\n",
+			d.EventID,
+			d.Time.In(guild.Location()).Format("2006-01-02"),
+			proofLink(guild, d.Proof),
+			d.Disputes,
+		))
+	}
+	return sb.String()
+}
+
+// resolveDispute lets an admin settle a disputed swince for good
+func (b *Bot) resolveDispute(ctx context.Context, guild database.Guild, eventID, verdict string) string {
+	event, err := b.db.GetEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && event.GuildID != guild.GuildID) {
+		return fmt.Sprintf("No swince with ID `%s`.", eventID)
+	} else if err != nil {
+		logger.ErrorContext(ctx, "Failed to get event", logging.ErrKey, err, "event_id", eventID)
+		return ":warning: Unable to resolve that swince."
+	}
+	if event.Verification != database.VerificationDisputed {
+		return fmt.Sprintf("Swince `%s` isn't disputed, it's **%s**.", eventID, event.Verification)
+	}
+
+	if err := b.setVerification(ctx, guild, event, verdict); err != nil {
+		logger.ErrorContext(ctx, "Failed to resolve dispute", logging.ErrKey, err, "event_id", eventID)
+		return ":warning: Unable to resolve that swince."
+	}
+	return fmt.Sprintf(":white_check_mark: Swince `%s` is now **%s**.", eventID, verdict)
+}
+
+// announceApproval celebrates the participants of an approved swince
+func (b *Bot) announceApproval(ctx context.Context, guild database.Guild, eventID string) {
+	if guild.AnnouncementChannelID == nil {
+		return
+	}
+	participants, err := b.db.GetEventParticipants(ctx, eventID)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to get event participants", logging.ErrKey, err, "event_id", eventID)
+		return
+	}
+
+	mentions := make([]string, 0, len(participants))
+	for _, p := range participants {
+		mentions = append(mentions, fmt.Sprintf("<@%d>", p))
+	}
+	b.announce(ctx, guild.AnnouncementChannelID, "approval:"+eventID, fmt.Sprintf(":tada: Swince by %s approved!", strings.Join(mentions, ", ")))
+}
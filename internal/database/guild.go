@@ -0,0 +1,44 @@
+package database
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminIDs parses the space separated admins of a guild (see Guilds.admins).
+// Malformed entries are skipped.
+func (g Guild) AdminIDs() []uint64 {
+	var ids []uint64
+	for _, field := range strings.Fields(g.Admins) {
+		if id, err := strconv.ParseUint(field, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IsAdmin reports whether the user may run the guild's admin commands
+func (g Guild) IsAdmin(userID uint64) bool {
+	return slices.Contains(g.AdminIDs(), userID)
+}
+
+// Location is the guild's timezone. Dates are shown in UTC when it can't be
+// loaded.
+func (g Guild) Location() *time.Location {
+	loc, err := time.LoadLocation(g.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FormatAdmins is the inverse of Guild.AdminIDs
+func FormatAdmins(ids []uint64) string {
+	fields := make([]string, 0, len(ids))
+	for _, id := range ids {
+		fields = append(fields, strconv.FormatUint(id, 10))
+	}
+	return strings.Join(fields, " ")
+}
@@ -6,10 +6,9 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
-	"log/slog"
 	"os"
+	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ChausseBenjamin/swincebot/internal/logging"
@@ -22,6 +21,8 @@ var schemaModel string
 
 var errSchemaMismatch = errors.New("database schema does not match expected definition")
 
+var logger = logging.Subsystem("db")
+
 type pragmaConstraint struct {
 	pragma string
 	value  string
@@ -36,7 +37,7 @@ type ProtoDB struct {
 func newProtoDB(db *sql.DB) *ProtoDB {
 	return &ProtoDB{
 		DB:      db,
-		Queries: New(db),
+		Queries: New(instrumentedDB{db}),
 	}
 }
 
@@ -49,11 +50,11 @@ var preConditions = []pragmaConstraint{
 	{"temp_store", "MEMORY"},
 }
 
-// Setup opens the SQLite DB at path, verifies its integrity and schema,
-// and returns the valid DB handle. If any check fails, it backs up the old
-// file and reinitializes the DB using the schema definitions.
+// Setup opens the SQLite DB at path, verifies its integrity, migrates its
+// schema and returns the valid DB handle. A corrupt file is backed up and
+// replaced by a blank DB using the schema definitions.
 func Setup(ctx context.Context, path string, cfg *util.ConfigStore) (*ProtoDB, error) {
-	slog.DebugContext(ctx, "Setting up database connection")
+	logger.DebugContext(ctx, "Setting up database connection")
 	var (
 		db    *sql.DB
 		res   sql.Result
@@ -72,7 +73,7 @@ func Setup(ctx context.Context, path string, cfg *util.ConfigStore) (*ProtoDB, e
 		// Attempt to open the existing one otherwise
 		db, err = sql.Open("sqlite3", path)
 		if err != nil {
-			slog.ErrorContext(ctx, "failed to open DB", logging.ErrKey, err)
+			logger.ErrorContext(ctx, "failed to open DB", logging.ErrKey, err)
 			backup(ctx, path)
 			db, err = newDB(ctx, path)
 		}
@@ -90,7 +91,7 @@ func Setup(ctx context.Context, path string, cfg *util.ConfigStore) (*ProtoDB, e
 			db.Close()
 			backup(ctx, path)
 			db, err = newDB(ctx, path)
-			slog.ErrorContext(ctx,
+			logger.ErrorContext(ctx,
 				"Integrity check failed",
 				"condition", cond.pragma,
 				"value", cond.value,
@@ -105,9 +106,9 @@ func Setup(ctx context.Context, path string, cfg *util.ConfigStore) (*ProtoDB, e
 		queryErr := db.QueryRow("PRAGMA integrity_check;").Scan(&check)
 		if queryErr != nil || check != "ok" {
 			if queryErr != nil {
-				slog.ErrorContext(ctx, "integrity check query failed", logging.ErrKey, queryErr)
+				logger.ErrorContext(ctx, "integrity check query failed", logging.ErrKey, queryErr)
 			} else {
-				slog.ErrorContext(ctx, "integrity check fails", "integrity", check)
+				logger.ErrorContext(ctx, "integrity check fails", "integrity", check)
 			}
 			db.Close()
 			backup(ctx, path)
@@ -115,19 +116,20 @@ func Setup(ctx context.Context, path string, cfg *util.ConfigStore) (*ProtoDB, e
 		}
 	}
 
-	if err == nil {
-		schemaErr := validateSchema(ctx, db, schemaModel)
-		if schemaErr != nil {
-			slog.ErrorContext(ctx, "schema validation failed", logging.ErrKey, schemaErr)
-			db.Close()
-			backup(ctx, path)
-			db, err = newDB(ctx, path)
-		}
+	if err != nil {
+		return nil, err
 	}
 
-	if err != nil {
+	// Unlike corruption, an outdated schema holds data worth keeping: refuse
+	// to start rather than replace the file
+	if err := migrate(ctx, db, cfg); err != nil {
+		db.Close()
 		return nil, err
 	}
+	if err := validateSchema(ctx, db, schemaModel); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("validating schema of %s: %w", path, err)
+	}
 
 	return newProtoDB(db), nil
 }
@@ -139,53 +141,30 @@ func backup(ctx context.Context, path string) {
 		backupPath = fmt.Sprintf("%s-%s.bak", path, time.Now().UTC().Format(time.RFC3339))
 	}
 	if err := os.Rename(path, backupPath); err != nil {
-		slog.ErrorContext(ctx, "failed to backup file",
+		logger.ErrorContext(ctx, "failed to backup file",
 			logging.ErrKey, err,
 			"original", path,
 			"backup", backupPath,
 		)
 	} else {
-		slog.InfoContext(ctx, "Backed up corrupt DB",
+		logger.InfoContext(ctx, "Backed up corrupt DB",
 			"original", path,
 			"backup", backupPath,
 		)
 	}
 }
 
-// normalizeSQL removes SQL comments, converts to lowercase,
-// collapses whitespace, and removes a trailing semicolon.
-func normalizeSQL(sqlStr string) string {
-	sqlStr = removeSQLComments(sqlStr)
-	sqlStr = strings.ToLower(sqlStr)
-	sqlStr = strings.ReplaceAll(sqlStr, "create table sqlite_sequence(name,seq)", "")
-	sqlStr = strings.ReplaceAll(sqlStr, ";", "")
-	sqlStr = strings.ReplaceAll(sqlStr, "\n", " ")
-	sqlStr = strings.Join(strings.Fields(sqlStr), " ")
-	return sqlStr
-}
-
-// removeSQLComments strips out any '--' style comments.
-func removeSQLComments(sqlStr string) string {
-	lines := strings.Split(sqlStr, "\n")
-	for i, line := range lines {
-		if idx := strings.Index(line, "--"); idx != -1 {
-			lines[i] = line[:idx]
-		}
-	}
-	return strings.Join(lines, " ")
-}
-
 // newDB creates a new database at path using the expected schema definitions.
 func newDB(ctx context.Context, path string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to create DB", logging.ErrKey, err)
+		logger.ErrorContext(ctx, "failed to create DB", logging.ErrKey, err)
 		return nil, err
 	}
 
 	// Set the required PRAGMAs.
 	if _, err := db.Exec("PRAGMA foreign_keys = on; PRAGMA journal_mode = wal;"); err != nil {
-		slog.ErrorContext(ctx, "failed to set pragmas", logging.ErrKey, err)
+		logger.ErrorContext(ctx, "failed to set pragmas", logging.ErrKey, err)
 		db.Close()
 		return nil, err
 	}
@@ -193,65 +172,100 @@ func newDB(ctx context.Context, path string) (*sql.DB, error) {
 	// Create tables inside a transaction.
 	tx, err := db.Begin()
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to begin transaction for schema initialization", logging.ErrKey, err)
+		logger.ErrorContext(ctx, "failed to begin transaction for schema initialization", logging.ErrKey, err)
 		db.Close()
 		return nil, err
 	}
 
-	if _, err := tx.ExecContext(ctx, schemaModel); err != nil {
-		slog.ErrorContext(ctx, "failed to initialize schema", logging.ErrKey, err)
+	_, err = tx.ExecContext(ctx, schemaModel)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", schemaVersion()))
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to initialize schema", logging.ErrKey, err)
 		if errRollback := tx.Rollback(); errRollback != nil {
-			slog.ErrorContext(ctx, "failed to rollback schema initialization", logging.ErrKey, errRollback)
+			logger.ErrorContext(ctx, "failed to rollback schema initialization", logging.ErrKey, errRollback)
 		}
 		db.Close()
 		return nil, err
 	}
 
 	if err := tx.Commit(); err != nil {
-		slog.ErrorContext(ctx, "failed to commit schema initialization", logging.ErrKey, err)
+		logger.ErrorContext(ctx, "failed to commit schema initialization", logging.ErrKey, err)
 		db.Close()
 		return nil, err
 	}
 
-	slog.InfoContext(ctx, "created new blank DB with valid schema", "path", path)
+	logger.InfoContext(ctx, "created new blank DB with valid schema", "path", path)
 	return db, nil
 }
 
+// validateSchema compares the structure of the tables to the one described
+// by schema.sql. Text can't be compared: migrated tables are defined by
+// several statements.
 func validateSchema(ctx context.Context, db *sql.DB, expectedSchema string) error {
-	actualSchema, err := fetchSchema(db)
+	model, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to fetch schema", logging.ErrKey, err)
-		return errSchemaMismatch
+		return err
+	}
+	defer model.Close()
+	model.SetMaxOpenConns(1) // every connection gets its own memory database
+	if _, err := model.ExecContext(ctx, expectedSchema); err != nil {
+		return fmt.Errorf("loading expected schema: %w", err)
 	}
 
-	normalizedExpected := normalizeSQL(expectedSchema)
-	normalizedActual := normalizeSQL(actualSchema)
-	if normalizedExpected != normalizedActual {
-		slog.ErrorContext(ctx, "schema does not match expected schema",
-			"expected", normalizedExpected,
-			"actual", normalizedActual,
+	expected, err := describeSchema(ctx, model)
+	if err != nil {
+		return fmt.Errorf("describing expected schema: %w", err)
+	}
+	actual, err := describeSchema(ctx, db)
+	if err != nil {
+		return fmt.Errorf("describing schema: %w", err)
+	}
+
+	missing := slices.DeleteFunc(slices.Clone(expected), func(s string) bool { return slices.Contains(actual, s) })
+	unexpected := slices.DeleteFunc(slices.Clone(actual), func(s string) bool { return slices.Contains(expected, s) })
+	if len(missing) > 0 || len(unexpected) > 0 {
+		logger.ErrorContext(ctx, "schema does not match expected schema",
+			"missing", missing,
+			"unexpected", unexpected,
 		)
 		return errSchemaMismatch
 	}
 	return nil
 }
 
-// fetchSchema retrieves the entire schema definition from the database.
-func fetchSchema(db *sql.DB) (string, error) {
-	rows, err := db.Query("SELECT sql FROM sqlite_master WHERE type='table'")
+// describeSchema lists the columns, unique indexes and foreign keys of every
+// table, one line each
+func describeSchema(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH tables AS (
+			SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		)
+		SELECT t.name || ' column ' || c.name || ' ' || lower(c.type) || ' notnull=' || c."notnull" || ' pk=' || c.pk
+		FROM tables t, pragma_table_info(t.name) c
+		UNION ALL
+		SELECT t.name || ' unique (' || (
+			SELECT group_concat(name, ', ') FROM (SELECT name FROM pragma_index_info(i.name) ORDER BY seqno)
+		) || ')'
+		FROM tables t, pragma_index_list(t.name) i
+		WHERE i."unique"
+		UNION ALL
+		SELECT t.name || ' foreign key ' || f."from" || ' references ' || f."table" || '(' || coalesce(f."to", '') || ') on delete ' || f.on_delete
+		FROM tables t, pragma_foreign_key_list(t.name) f
+		ORDER BY 1`)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var sb strings.Builder
+	var lines []string
 	for rows.Next() {
-		var sql string
-		if err := rows.Scan(&sql); err != nil {
-			return "", err
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
 		}
-		sb.WriteString(sql)
-		sb.WriteString("\n")
+		lines = append(lines, line)
 	}
-	return sb.String(), rows.Err()
+	return lines, rows.Err()
 }
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/ChausseBenjamin/swincebot/internal/tracing"
+)
+
+// instrumentedDB times and traces every sqlc query, named after the
+// "-- name: X :kind" comment sqlc leaves at the top of each of them
+type instrumentedDB struct {
+	DBTX
+}
+
+// observe opens the span of a query and returns what ends it
+func observe(ctx context.Context, query string) (context.Context, func(error)) {
+	name := queryName(query)
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, tracing.KindClient, "db "+name,
+		slog.String("db.system", "sqlite"),
+		slog.String("db.operation", name),
+	)
+	return ctx, func(err error) {
+		metrics.Queries.Since(start, name)
+		span.RecordError(err)
+		span.End()
+	}
+}
+
+func (db instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := observe(ctx, query)
+	res, err := db.DBTX.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+// QueryContext is observed until the first rows are available, not while
+// they are being scanned
+func (db instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := observe(ctx, query)
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (db instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := observe(ctx, query)
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// WithTx runs the queries in tx, still observing them
+func (db *ProtoDB) WithTx(tx *sql.Tx) *Queries {
+	return New(instrumentedDB{tx})
+}
+
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// brokenFulfillments explains the problems reported by ListBrokenFulfillments
+var brokenFulfillments = map[string]string{
+	"missing":           "is fulfilled by a swince that doesn't exist",
+	"other-guild":       "is fulfilled by a swince from another server",
+	"no-nominee":        "is fulfilled but nominated no-one",
+	"wrong-participant": "is fulfilled by someone other than its nominee",
+}
+
+// Check inspects the database at path without modifying it, unlike Setup
+// which replaces a database failing its checks. It returns every problem found.
+func Check(ctx context.Context, path string) ([]string, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	var problems []string
+
+	integrity, err := pragmaRows(ctx, db, "PRAGMA integrity_check;")
+	if err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	if len(integrity) != 1 || integrity[0] != "ok" {
+		for _, line := range integrity {
+			problems = append(problems, "integrity: "+line)
+		}
+		// Nothing else can be trusted on a corrupted file
+		return problems, nil
+	}
+
+	if err := validateSchema(ctx, db, schemaModel); err != nil {
+		// Queries would fail on an unknown schema
+		return append(problems, "schema: "+err.Error()+" (the bot would back it up and start over)"), nil
+	}
+
+	rows, err := db.QueryContext(ctx, "PRAGMA foreign_key_check;")
+	if err != nil {
+		return nil, fmt.Errorf("checking foreign keys: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var (
+			table, parent string
+			rowID, fkID   sql.NullInt64
+		)
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return nil, fmt.Errorf("checking foreign keys: %w", err)
+		}
+		problems = append(problems, fmt.Sprintf("foreign key: row %d of %s references a missing %s", rowID.Int64, table, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("checking foreign keys: %w", err)
+	}
+
+	broken, err := New(db).ListBrokenFulfillments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("checking fulfillments: %w", err)
+	}
+	for _, b := range broken {
+		problems = append(problems, fmt.Sprintf("fulfillment: swince %s %s (%s)",
+			b.SwinceID, brokenFulfillments[b.Problem], b.FulfillmentID.String,
+		))
+	}
+
+	return problems, nil
+}
+
+// Vacuum rebuilds the database file to reclaim the space of deleted rows
+func (db *ProtoDB) Vacuum(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, "VACUUM;"); err != nil {
+		return fmt.Errorf("vacuuming: %w", err)
+	}
+	// Leave the main file as the only copy of the data
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);"); err != nil {
+		return fmt.Errorf("checkpointing: %w", err)
+	}
+	return nil
+}
+
+// Ready checks the database still answers and isn't corrupted. quick_check
+// skips the index verifications of integrity_check to stay cheap enough for
+// frequent probes.
+func (db *ProtoDB) Ready(ctx context.Context) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+	var check string
+	if err := db.QueryRowContext(ctx, "PRAGMA quick_check(1);").Scan(&check); err != nil {
+		return fmt.Errorf("checking integrity: %w", err)
+	}
+	if check != "ok" {
+		return fmt.Errorf("database is corrupted: %s", check)
+	}
+	return nil
+}
+
+func pragmaRows(ctx context.Context, db *sql.DB, pragma string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, pragma)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+)
+
+// ErrSeedGuildNeeded is returned when events and seasons recorded before
+// the bot served several servers can't be assigned to one
+var ErrSeedGuildNeeded = errors.New("existing events and seasons need the server they belong to")
+
+// migration upgrades a database from the previous schema version. Databases
+// left by development builds may already hold part of a step's change, so
+// steps check before altering anything.
+type migration struct {
+	name string
+	up   func(ctx context.Context, tx *sql.Tx, cfg *util.ConfigStore) error
+}
+
+// migrations lead the schema of the first release up to schema.sql. The
+// version of a database (PRAGMA user_version) is the number of steps it went
+// through: never reorder or remove them, only append.
+var migrations = []migration{
+	{"unique swince IDs", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		// Nominations reference swinces by ID alone
+		_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS swinces_swince_id ON Swinces(swince_id)`)
+		return err
+	}},
+	{"proof archives", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS Archives (
+			event_id TEXT PRIMARY KEY NOT NULL,
+			sha256 TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			duplicate_of TEXT,
+			reviewed INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE,
+			FOREIGN KEY (duplicate_of) REFERENCES Events(event_id) ON DELETE SET NULL
+		)`)
+		return err
+	}},
+	{"peer verification", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS Votes (
+			event_id TEXT NOT NULL,
+			voter_id INTEGER NOT NULL,
+			approve INTEGER NOT NULL,
+			PRIMARY KEY (event_id, voter_id),
+			FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE
+		)`)
+		if err != nil {
+			return err
+		}
+
+		exists, err := hasColumn(ctx, tx, "Events", "verification")
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `ALTER TABLE Events ADD COLUMN verification TEXT NOT NULL DEFAULT 'pending'`)
+		if err != nil {
+			return err
+		}
+		// Swinces submitted before verification existed already counted
+		_, err = tx.ExecContext(ctx, `UPDATE Events SET verification = 'approved'`)
+		return err
+	}},
+	{"API tokens", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS Tokens (
+			token_id TEXT PRIMARY KEY NOT NULL,
+			user_id INTEGER NOT NULL,
+			scopes TEXT NOT NULL,
+			issued_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP
+		)`)
+		return err
+	}},
+	{"guilds", func(ctx context.Context, tx *sql.Tx, cfg *util.ConfigStore) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS Guilds (
+			guild_id INTEGER PRIMARY KEY NOT NULL,
+			channel_id INTEGER NOT NULL,
+			admins TEXT NOT NULL DEFAULT '',
+			ruleset TEXT NOT NULL DEFAULT 'v0'
+		)`)
+		if err != nil {
+			return err
+		}
+
+		var rows int
+		err = tx.QueryRowContext(ctx, `SELECT (SELECT count(*) FROM Events) + (SELECT count(*) FROM Seasons)`).Scan(&rows)
+		if err != nil {
+			return err
+		}
+		if rows > 0 {
+			if cfg.SeedGuildID == 0 || cfg.SeedChannelID == 0 {
+				return ErrSeedGuildNeeded
+			}
+			_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO Guilds (guild_id, channel_id, admins) VALUES (?, ?, ?)`,
+				cfg.SeedGuildID, cfg.SeedChannelID, cfg.SeedAdmins,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, table := range []string{"Events", "Swinces"} {
+			exists, err := hasColumn(ctx, tx, table, "guild_id")
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			// The default is only there to satisfy ALTER TABLE: the column
+			// gets filled right away
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(
+				`ALTER TABLE %s ADD COLUMN guild_id INTEGER NOT NULL DEFAULT 0 REFERENCES Guilds(guild_id) ON DELETE CASCADE`, table,
+			))
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET guild_id = ?`, table), cfg.SeedGuildID); err != nil {
+				return err
+			}
+		}
+
+		// The primary key of seasons changes, which takes a new table
+		exists, err := hasColumn(ctx, tx, "Seasons", "guild_id")
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `CREATE TABLE Seasons_v2 (
+			guild_id INTEGER NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			ruleset TEXT NOT NULL,
+			PRIMARY KEY (guild_id, start_time),
+			FOREIGN KEY (guild_id) REFERENCES Guilds(guild_id) ON DELETE CASCADE
+		)`)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO Seasons_v2 (guild_id, start_time, ruleset)
+			SELECT ?, start_time, ruleset FROM Seasons`, cfg.SeedGuildID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DROP TABLE Seasons; ALTER TABLE Seasons_v2 RENAME TO Seasons`)
+		return err
+	}},
+	{"guild settings", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		for _, col := range []struct{ name, def string }{
+			{"announcement_channel_id", "INTEGER"},
+			{"admin_role_id", "INTEGER"},
+			{"timezone", "TEXT NOT NULL DEFAULT 'UTC'"},
+		} {
+			exists, err := hasColumn(ctx, tx, "Guilds", col.name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE Guilds ADD COLUMN %s %s`, col.name, col.def)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"outbound messages", func(ctx context.Context, tx *sql.Tx, _ *util.ConfigStore) error {
+		_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS OutboundMessages (
+			idempotency_key TEXT PRIMARY KEY NOT NULL,
+			channel_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			sent_at TIMESTAMP NOT NULL
+		)`)
+		return err
+	}},
+}
+
+// schemaVersion is the version of databases created from schema.sql
+func schemaVersion() int {
+	return len(migrations)
+}
+
+// migrate runs the steps a database hasn't been through yet, each in its own
+// transaction
+func migrate(ctx context.Context, db *sql.DB, cfg *util.ConfigStore) error {
+	// Foreign keys are toggled per connection, and not within transactions
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var version int
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	switch {
+	case version == schemaVersion():
+		return nil
+	case version > schemaVersion():
+		return fmt.Errorf("database schema version %d is newer than this build's (%d)", version, schemaVersion())
+	}
+
+	// Steps rebuilding tables would otherwise trip over references to them
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA foreign_keys = ON") //nolint:errcheck
+
+	for ; version < schemaVersion(); version++ {
+		step := migrations[version]
+		if err := runMigration(ctx, conn, step, version+1, cfg); err != nil {
+			return fmt.Errorf("migrating database to version %d (%s): %w", version+1, step.name, err)
+		}
+		logger.InfoContext(ctx, "Migrated database", "version", version+1, "migration", step.name)
+	}
+	return nil
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, step migration, version int, cfg *util.ConfigStore) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := step.up(ctx, tx, cfg); err != nil {
+		return err
+	}
+
+	// Checked by hand since foreign keys are off
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	violation := rows.Next()
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	if violation {
+		return errors.New("foreign key constraints violated")
+	}
+
+	// PRAGMA arguments can't be bound
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasColumn tells whether a table has a column, for steps adding one
+func hasColumn(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	var found bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT count(*) > 0 FROM pragma_table_info(?) WHERE name = ?", table, column,
+	).Scan(&found)
+	return found, err
+}
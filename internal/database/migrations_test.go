@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+)
+
+// baselineSchema is the schema of the first release, at version 0
+const baselineSchema = `
+CREATE TABLE Events (
+    event_id TEXT PRIMARY KEY NOT NULL DEFAULT (
+        lower(
+            hex(randomblob(4)) || '-' ||
+            hex(randomblob(2)) || '-' ||
+            '4' || substr(hex(randomblob(2)), 2) || '-' ||
+            substr('89ab', abs(random()) % 4 + 1, 1) ||
+            substr(hex(randomblob(2)), 2) || '-' ||
+            hex(randomblob(6))
+        )
+    ),
+    time TIMESTAMP NOT NULL, -- submission time (should default to now)
+    proof INTEGER -- discord messageID of the video on swince channel
+);
+
+CREATE TABLE Swinces (
+    event_id TEXT NOT NULL, -- video in which the swince was performed (multiple swinces during a single event possible)
+    swince_id TEXT NOT NULL DEFAULT (
+        lower(
+            hex(randomblob(4)) || '-' ||
+            hex(randomblob(2)) || '-' ||
+            '4' || substr(hex(randomblob(2)), 2) || '-' ||
+            substr('89ab', abs(random()) % 4 + 1, 1) ||
+            substr(hex(randomblob(2)), 2) || '-' ||
+            hex(randomblob(6))
+        )
+    ),
+    participant_id INTEGER NOT NULL, -- person performing the swince (Discord user ID)
+    nominee_id INTEGER, -- person nominated: optional as you *can* nominate nobody (loser)
+    fulfillment_id TEXT, -- ID of the Swince that fullfills the nomination
+    PRIMARY KEY (event_id, swince_id),
+    FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE,
+    FOREIGN KEY (fulfillment_id) REFERENCES Swinces(swince_id)
+);
+
+CREATE TABLE Seasons (
+    start_time TIMESTAMP PRIMARY KEY NOT NULL,
+    ruleset TEXT NOT NULL
+);
+`
+
+// baselineDB creates a version 0 database, with a nomination fulfilled in a
+// later event and a season when withRows is set
+func baselineDB(t *testing.T, withRows bool) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "swincebot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	if !withRows {
+		return db
+	}
+	_, err = db.Exec(`
+		INSERT INTO Events (event_id, time, proof) VALUES
+			('e1', '2024-01-01 10:00:00+00:00', 101),
+			('e2', '2024-01-02 10:00:00+00:00', 102);
+		INSERT INTO Swinces (event_id, swince_id, participant_id, nominee_id, fulfillment_id) VALUES
+			('e2', 's2', 2, NULL, NULL),
+			('e1', 's1', 1, 2, 's2');
+		INSERT INTO Seasons (start_time, ruleset) VALUES ('2024-01-01 00:00:00+00:00', 'v0');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	ctx := context.Background()
+	db := baselineDB(t, true)
+
+	cfg := &util.ConfigStore{SeedGuildID: 42, SeedChannelID: 7, SeedAdmins: "1"}
+	if err := migrate(ctx, db, cfg); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != schemaVersion() {
+		t.Errorf("user_version = %d, want %d", version, schemaVersion())
+	}
+	if err := validateSchema(ctx, db, schemaModel); err != nil {
+		t.Errorf("validateSchema after migrating: %v", err)
+	}
+
+	var channelID int
+	var admins string
+	if err := db.QueryRow("SELECT channel_id, admins FROM Guilds WHERE guild_id = 42").Scan(&channelID, &admins); err != nil {
+		t.Fatalf("seed guild: %v", err)
+	}
+	if channelID != 7 || admins != "1" {
+		t.Errorf("seed guild has channel %d and admins %q, want 7 and %q", channelID, admins, "1")
+	}
+
+	rows, err := db.Query("SELECT event_id, guild_id, proof, verification FROM Events ORDER BY event_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var events []string
+	for rows.Next() {
+		var id, verification string
+		var guildID, proof int
+		if err := rows.Scan(&id, &guildID, &proof, &verification); err != nil {
+			t.Fatal(err)
+		}
+		if guildID != 42 || verification != VerificationApproved {
+			t.Errorf("event %s has guild %d and verification %q, want 42 and %q", id, guildID, verification, VerificationApproved)
+		}
+		events = append(events, id)
+	}
+	if len(events) != 2 {
+		t.Errorf("events after migrating: %v, want e1 and e2", events)
+	}
+
+	var guildID int
+	var fulfillment sql.NullString
+	err = db.QueryRow("SELECT guild_id, fulfillment_id FROM Swinces WHERE swince_id = 's1'").Scan(&guildID, &fulfillment)
+	if err != nil {
+		t.Fatalf("nomination: %v", err)
+	}
+	if guildID != 42 || fulfillment.String != "s2" {
+		t.Errorf("nomination has guild %d and fulfillment %q, want 42 and s2", guildID, fulfillment.String)
+	}
+
+	var seasons int
+	var ruleset string
+	err = db.QueryRow("SELECT count(*), max(ruleset) FROM Seasons WHERE guild_id = 42 AND start_time = '2024-01-01 00:00:00+00:00'").Scan(&seasons, &ruleset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seasons != 1 || ruleset != "v0" {
+		t.Errorf("found %d seasons with ruleset %q, want 1 with v0", seasons, ruleset)
+	}
+
+	// Migrating again is a no-op
+	if err := migrate(ctx, db, cfg); err != nil {
+		t.Errorf("migrating an up to date database: %v", err)
+	}
+}
+
+func TestMigrateEmptyBaseline(t *testing.T) {
+	ctx := context.Background()
+	db := baselineDB(t, false)
+
+	// Nothing to assign to a guild: no seed needed
+	if err := migrate(ctx, db, &util.ConfigStore{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := validateSchema(ctx, db, schemaModel); err != nil {
+		t.Errorf("validateSchema after migrating: %v", err)
+	}
+}
+
+func TestMigrateNeedsSeedGuild(t *testing.T) {
+	ctx := context.Background()
+	db := baselineDB(t, true)
+
+	err := migrate(ctx, db, &util.ConfigStore{})
+	if !errors.Is(err, ErrSeedGuildNeeded) {
+		t.Fatalf("migrate without a seed guild returned %v, want ErrSeedGuildNeeded", err)
+	}
+
+	// Steps before the guilds one stay applied, the rest waits for a seed
+	var version, events int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version >= schemaVersion() {
+		t.Errorf("user_version = %d after a failed migration", version)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM Events").Scan(&events); err != nil {
+		t.Fatal(err)
+	}
+	if events != 2 {
+		t.Errorf("%d events left after a failed migration, want 2", events)
+	}
+
+	if err := migrate(ctx, db, &util.ConfigStore{SeedGuildID: 42, SeedChannelID: 7}); err != nil {
+		t.Fatalf("migrate once seeded: %v", err)
+	}
+	if err := validateSchema(ctx, db, schemaModel); err != nil {
+		t.Errorf("validateSchema after migrating: %v", err)
+	}
+}
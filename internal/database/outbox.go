@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MessageSent returns the ID of the Discord message already sent with the
+// idempotency key, if any
+func (db *ProtoDB) MessageSent(ctx context.Context, key string) (string, bool, error) {
+	msg, err := db.GetOutboundMessage(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("looking up outbound message %s: %w", key, err)
+	}
+	return msg.MessageID, true, nil
+}
+
+// RecordMessage remembers a message was sent so it never gets sent twice
+func (db *ProtoDB) RecordMessage(ctx context.Context, key, channelID, messageID string) error {
+	err := db.InsertOutboundMessage(ctx, InsertOutboundMessageParams{
+		IdempotencyKey: key,
+		ChannelID:      channelID,
+		MessageID:      messageID,
+		SentAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("recording outbound message %s: %w", key, err)
+	}
+	return nil
+}
@@ -1,3 +1,13 @@
+CREATE TABLE Guilds (
+    guild_id INTEGER PRIMARY KEY NOT NULL, -- Discord server the bot serves
+    channel_id INTEGER NOT NULL, -- where swinces get reposted for peer verification
+    announcement_channel_id INTEGER, -- where approved swinces get announced (none if null)
+    admins TEXT NOT NULL DEFAULT '', -- space separated Discord user IDs allowed to run admin commands
+    admin_role_id INTEGER, -- members with that role may also run admin commands
+    timezone TEXT NOT NULL DEFAULT 'UTC', -- IANA name, used when displaying dates
+    ruleset TEXT NOT NULL DEFAULT 'v0' -- builtin name or file path, scores season 0
+);
+
 CREATE TABLE Events (
     event_id TEXT PRIMARY KEY NOT NULL DEFAULT (
         lower(
@@ -9,13 +19,17 @@ CREATE TABLE Events (
             hex(randomblob(6))
         )
     ),
+    guild_id INTEGER NOT NULL, -- server the swince was submitted on
     time TIMESTAMP NOT NULL, -- submission time (should default to now)
-    proof INTEGER -- discord messageID of the video on swince channel
+    proof INTEGER, -- discord messageID of the video on swince channel
+    verification TEXT NOT NULL DEFAULT 'pending', -- pending, approved, disputed or rejected
+    FOREIGN KEY (guild_id) REFERENCES Guilds(guild_id) ON DELETE CASCADE
 );
 
 CREATE TABLE Swinces (
     event_id TEXT NOT NULL, -- video in which the swince was performed (multiple swinces during a single event possible)
-    swince_id TEXT NOT NULL DEFAULT (
+    guild_id INTEGER NOT NULL, -- same as the event's, nominations only carry over within a server
+    swince_id TEXT NOT NULL UNIQUE DEFAULT (
         lower(
             hex(randomblob(4)) || '-' ||
             hex(randomblob(2)) || '-' ||
@@ -30,11 +44,48 @@ CREATE TABLE Swinces (
     fulfillment_id TEXT, -- ID of the Swince that fullfills the nomination
     PRIMARY KEY (event_id, swince_id),
     FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE,
+    FOREIGN KEY (guild_id) REFERENCES Guilds(guild_id) ON DELETE CASCADE,
     FOREIGN KEY (fulfillment_id) REFERENCES Swinces(swince_id)
 );
 
 CREATE TABLE Seasons (
-    start_time TIMESTAMP PRIMARY KEY NOT NULL,
-    ruleset TEXT NOT NULL
+    guild_id INTEGER NOT NULL, -- every server keeps its own seasons
+    start_time TIMESTAMP NOT NULL,
+    ruleset TEXT NOT NULL,
+    PRIMARY KEY (guild_id, start_time),
+    FOREIGN KEY (guild_id) REFERENCES Guilds(guild_id) ON DELETE CASCADE
+);
+
+CREATE TABLE Archives (
+    event_id TEXT PRIMARY KEY NOT NULL,
+    sha256 TEXT NOT NULL, -- hash of the archived video (also its file name in the archive)
+    size INTEGER NOT NULL, -- bytes
+    duplicate_of TEXT, -- earlier event whose proof has identical content (suspected duplicate)
+    reviewed INTEGER NOT NULL DEFAULT 0, -- an admin looked at the duplicate flag
+    FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE,
+    FOREIGN KEY (duplicate_of) REFERENCES Events(event_id) ON DELETE SET NULL
 );
 
+CREATE TABLE Votes (
+    event_id TEXT NOT NULL, -- event being verified by peers
+    voter_id INTEGER NOT NULL, -- Discord user ID of the voter
+    approve INTEGER NOT NULL, -- 1 approves the event, 0 disputes it
+    PRIMARY KEY (event_id, voter_id),
+    FOREIGN KEY (event_id) REFERENCES Events(event_id) ON DELETE CASCADE
+);
+
+CREATE TABLE Tokens (
+    token_id TEXT PRIMARY KEY NOT NULL, -- jti claim of the API token
+    user_id INTEGER NOT NULL, -- Discord user the token was minted for
+    scopes TEXT NOT NULL, -- space separated list of scopes
+    issued_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP -- set once the token gets revoked
+);
+
+CREATE TABLE OutboundMessages (
+    idempotency_key TEXT PRIMARY KEY NOT NULL, -- chosen by the sender, ex: "proof:<event_id>"
+    channel_id TEXT NOT NULL, -- Discord channel (or DM) the message went to
+    message_id TEXT NOT NULL, -- Discord ID of the message sent
+    sent_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,11 @@
+package database
+
+// Verification states of an event (see Events.verification).
+// Only approved events, and pending ones whose dispute window elapsed, count
+// toward scores.
+const (
+	VerificationPending  = "pending"
+	VerificationApproved = "approved"
+	VerificationDisputed = "disputed"
+	VerificationRejected = "rejected"
+)
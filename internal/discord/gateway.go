@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Backoff spaces out connection attempts: the delay doubles after every
+// failure, from Initial up to Max
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// delay returns the wait before the given retry (starting at 0). Half of it
+// is random so restarted replicas don't all retry at once.
+func (b Backoff) delay(retry int) time.Duration {
+	d := b.Initial
+	for ; retry > 0 && d < b.Max; retry-- {
+		d *= 2
+	}
+	d = min(d, b.Max)
+	return d/2 + rand.N(d/2+1)
+}
+
+// Connect opens the gateway connection, retrying until it succeeds or ctx is
+// done. Once connected, discordgo takes care of resuming after network
+// issues.
+func (c *Client) Connect(ctx context.Context, backoff Backoff) error {
+	for retry := 0; ; retry++ {
+		err := c.open()
+		if err == nil {
+			return nil
+		}
+
+		delay := backoff.delay(retry)
+		slog.WarnContext(ctx, "Could not connect to the Discord gateway, retrying",
+			logging.ErrKey, err,
+			"attempt", retry+1,
+			"retry_in", delay.Round(time.Millisecond),
+		)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("connecting to discord: %w", ctx.Err())
+		}
+	}
+}
+
+// open connects the session unless it already is (ex: after a token rotation)
+func (c *Client) open() error {
+	c.rotating.Lock()
+	defer c.rotating.Unlock()
+	if c.closed.Load() {
+		return errors.New("discord client is closed")
+	}
+	if err := c.session.Open(); err != nil && !errors.Is(err, discordgo.ErrWSAlreadyOpen) {
+		return fmt.Errorf("opening discord session: %w", err)
+	}
+	return nil
+}
+
+// monitorGateway logs connection losses along with how long they lasted
+func (c *Client) monitorGateway() {
+	s := c.session
+	var (
+		mu             sync.Mutex
+		connected      bool // a connection was made at least once
+		disconnectedAt time.Time
+	)
+
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) {
+		mu.Lock()
+		defer mu.Unlock()
+		if connected {
+			metrics.GatewayReconnects.Inc()
+		}
+		connected = true
+	})
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		if c.closed.Load() {
+			return // shutting down
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		disconnectedAt = time.Now()
+		metrics.GatewayDisconnects.Inc()
+		slog.Warn("Discord gateway disconnected")
+	})
+	back := func(how string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if disconnectedAt.IsZero() {
+			return
+		}
+		slog.Info("Discord gateway "+how, "downtime", time.Since(disconnectedAt).Round(time.Millisecond))
+		disconnectedAt = time.Time{}
+	}
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) { back("resumed") })
+	s.AddHandler(func(_ *discordgo.Session, _ *discordgo.Ready) { back("reconnected") })
+}
@@ -0,0 +1,293 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/metrics"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// outboxAttempts bounds how many times a message is tried when Discord
+	// keeps failing. Rate limits don't count: discordgo waits them out.
+	outboxAttempts = 5
+)
+
+var (
+	ErrOutboxClosed = errors.New("outbox is closed")
+
+	outboxBackoff = Backoff{Initial: time.Second, Max: 30 * time.Second}
+)
+
+// SentLog remembers the messages sent with an idempotency key
+type SentLog interface {
+	MessageSent(ctx context.Context, key string) (messageID string, found bool, err error)
+	RecordMessage(ctx context.Context, key, channelID, messageID string) error
+}
+
+// Message is waiting to be sent. DMs set UserID, with ChannelID when the DM
+// channel is already known.
+type Message struct {
+	ChannelID string
+	UserID    string
+	// Key prevents sending the same message twice, even across restarts.
+	// Optional.
+	Key  string
+	Send *discordgo.MessageSend
+}
+
+func (m Message) kind() string {
+	if m.UserID != "" {
+		return "dm"
+	}
+	return "channel"
+}
+
+// queue returns the key of the queue serializing the message, so messages to
+// the same channel (or user) are sent in order and share a rate-limit bucket
+func (m Message) queue() string {
+	if m.UserID != "" {
+		return "dm:" + m.UserID
+	}
+	return "channel:" + m.ChannelID
+}
+
+type outboxResult struct {
+	msg *discordgo.Message
+	err error
+}
+
+type outboxItem struct {
+	ctx  context.Context
+	msg  Message
+	done chan outboxResult // nil when nobody waits for the result
+}
+
+// sendFunc makes a single attempt at sending msg, opening the DM channel
+// first when needed
+type sendFunc func(ctx context.Context, msg Message) (*discordgo.Message, error)
+
+// Outbox sends messages one at a time per channel, retrying when Discord has
+// trouble. Each channel with pending messages has its own worker.
+type Outbox struct {
+	send sendFunc
+	log  SentLog
+
+	mu     sync.Mutex
+	queues map[string][]*outboxItem
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewOutbox(client *Client, log SentLog) *Outbox {
+	return newOutbox(client.sendMessage, log)
+}
+
+func newOutbox(send sendFunc, log SentLog) *Outbox {
+	return &Outbox{
+		send:   send,
+		log:    log,
+		queues: make(map[string][]*outboxItem),
+	}
+}
+
+// Send queues msg and waits until it was sent. When ctx is done first, msg
+// still gets sent but its result is lost.
+func (o *Outbox) Send(ctx context.Context, msg Message) (*discordgo.Message, error) {
+	done := make(chan outboxResult, 1)
+	if err := o.enqueue(ctx, msg, done); err != nil {
+		return nil, err
+	}
+	select {
+	case res := <-done:
+		return res.msg, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Enqueue queues msg without waiting for it. Failures are logged.
+func (o *Outbox) Enqueue(ctx context.Context, msg Message) error {
+	return o.enqueue(ctx, msg, nil)
+}
+
+func (o *Outbox) enqueue(ctx context.Context, msg Message, done chan outboxResult) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return ErrOutboxClosed
+	}
+
+	key := msg.queue()
+	pending, busy := o.queues[key]
+	o.queues[key] = append(pending, &outboxItem{
+		ctx:  context.WithoutCancel(ctx),
+		msg:  msg,
+		done: done,
+	})
+	metrics.OutboxDepth.Add(1, msg.kind())
+
+	if !busy {
+		o.wg.Add(1)
+		go o.work(key)
+	}
+	return nil
+}
+
+// work sends the messages of a queue until it is empty
+func (o *Outbox) work(key string) {
+	defer o.wg.Done()
+	var dmChannel string // resolved once per batch of DMs
+
+	for {
+		o.mu.Lock()
+		pending := o.queues[key]
+		if len(pending) == 0 {
+			delete(o.queues, key)
+			o.mu.Unlock()
+			return
+		}
+		item := pending[0]
+		o.queues[key] = pending[1:]
+		o.mu.Unlock()
+		metrics.OutboxDepth.Add(-1, item.msg.kind())
+
+		if item.msg.ChannelID == "" {
+			item.msg.ChannelID = dmChannel
+		}
+		sent, err := o.deliver(item.ctx, item.msg)
+		if err == nil {
+			dmChannel = sent.ChannelID
+		}
+
+		if item.done != nil {
+			item.done <- outboxResult{msg: sent, err: err}
+		} else if err != nil {
+			slog.ErrorContext(item.ctx, "Failed to send queued message", logging.ErrKey, err,
+				"channel_id", item.msg.ChannelID,
+				"user_id", item.msg.UserID,
+			)
+		}
+	}
+}
+
+// deliver sends msg unless it was already sent under the same key
+func (o *Outbox) deliver(ctx context.Context, msg Message) (*discordgo.Message, error) {
+	if msg.Key != "" {
+		id, found, err := o.log.MessageSent(ctx, msg.Key)
+		if err != nil {
+			metrics.OutboxMessages.Inc(metrics.OutboxFailed)
+			return nil, err
+		}
+		if found {
+			metrics.OutboxMessages.Inc(metrics.OutboxDuplicate)
+			slog.DebugContext(ctx, "Skipping message sent earlier", "key", msg.Key, "message_id", id)
+			return &discordgo.Message{ID: id, ChannelID: msg.ChannelID}, nil
+		}
+	}
+
+	var sent *discordgo.Message
+	err := retry(ctx, func() (err error) {
+		// Files are read again by every attempt
+		for _, f := range msg.Send.Files {
+			if seeker, ok := f.Reader.(io.Seeker); ok {
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+			}
+		}
+		sent, err = o.send(ctx, msg)
+		return err
+	})
+	if err != nil {
+		metrics.OutboxMessages.Inc(metrics.OutboxFailed)
+		return nil, err
+	}
+	metrics.OutboxMessages.Inc(metrics.OutboxSent)
+
+	if msg.Key != "" {
+		// The message is out: failing to record it only risks a duplicate
+		if err := o.log.RecordMessage(ctx, msg.Key, sent.ChannelID, sent.ID); err != nil {
+			slog.WarnContext(ctx, "Sent message may be sent again", logging.ErrKey, err)
+		}
+	}
+	return sent, nil
+}
+
+func (c *Client) sendMessage(ctx context.Context, msg Message) (*discordgo.Message, error) {
+	session := c.Session()
+
+	if msg.ChannelID == "" {
+		dm, err := session.UserChannelCreate(msg.UserID, discordgo.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("opening DM with %s: %w", msg.UserID, err)
+		}
+		msg.ChannelID = dm.ID
+	}
+
+	sent, err := session.ChannelMessageSendComplex(msg.ChannelID, msg.Send, discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("sending message to channel %s: %w", msg.ChannelID, err)
+	}
+	return sent, nil
+}
+
+// retry calls f until it succeeds, fails for good or runs out of attempts
+func retry(ctx context.Context, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || !transient(err) || attempt+1 == outboxAttempts {
+			return err
+		}
+
+		delay := outboxBackoff.delay(attempt)
+		slog.DebugContext(ctx, "Retrying Discord request", logging.ErrKey, err, "retry_in", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// transient tells whether a failed request may succeed if tried again
+func transient(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		return restErr.Response != nil && restErr.Response.StatusCode >= 500
+	}
+	// discordgo retries 502s itself, then gives up with an untyped error
+	if strings.Contains(err.Error(), "Exceeded Max retries") {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Close stops accepting messages and waits for the queued ones to be sent
+func (o *Outbox) Close(ctx context.Context) error {
+	o.mu.Lock()
+	o.closed = true
+	o.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for queued messages: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,255 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// memoryLog is a SentLog kept in memory
+type memoryLog struct {
+	mu   sync.Mutex
+	sent map[string]string // key -> message ID
+}
+
+func (l *memoryLog) MessageSent(_ context.Context, key string) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id, found := l.sent[key]
+	return id, found, nil
+}
+
+func (l *memoryLog) RecordMessage(_ context.Context, key, _, messageID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sent == nil {
+		l.sent = make(map[string]string)
+	}
+	l.sent[key] = messageID
+	return nil
+}
+
+// fakeDiscord records the messages sent through it. fail decides the error
+// returned by each attempt, nil lets the message through.
+type fakeDiscord struct {
+	mu       sync.Mutex
+	attempts int
+	sent     map[string][]string // channel ID -> message contents, in order
+	fail     func(attempt int, msg Message) error
+	sending  func(msg Message) // called before sending, outside the lock
+}
+
+func (f *fakeDiscord) send(_ context.Context, msg Message) (*discordgo.Message, error) {
+	f.mu.Lock()
+	f.attempts++
+	attempt := f.attempts
+	f.mu.Unlock()
+
+	if f.sending != nil {
+		f.sending(msg)
+	}
+	if f.fail != nil {
+		if err := f.fail(attempt, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sent == nil {
+		f.sent = make(map[string][]string)
+	}
+	f.sent[msg.ChannelID] = append(f.sent[msg.ChannelID], msg.Send.Content)
+	return &discordgo.Message{ID: strconv.Itoa(attempt), ChannelID: msg.ChannelID, Content: msg.Send.Content}, nil
+}
+
+func restError(status int) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: status}}
+}
+
+func message(channelID, content, key string) Message {
+	return Message{ChannelID: channelID, Key: key, Send: &discordgo.MessageSend{Content: content}}
+}
+
+func fastRetries(t *testing.T) {
+	saved := outboxBackoff
+	outboxBackoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	t.Cleanup(func() { outboxBackoff = saved })
+}
+
+func TestOutboxIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	discord := &fakeDiscord{}
+	log := &memoryLog{sent: map[string]string{"before-restart": "earlier"}}
+	outbox := newOutbox(discord.send, log)
+
+	first, err := outbox.Send(ctx, message("1", "hello", "greeting"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := outbox.Send(ctx, message("1", "hello", "greeting"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("resending under the same key returned message %s, want %s", again.ID, first.ID)
+	}
+
+	recorded, err := outbox.Send(ctx, message("1", "hello", "before-restart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.ID != "earlier" {
+		t.Errorf("a message recorded before a restart returned message %s, want earlier", recorded.ID)
+	}
+
+	// Messages without a key are always sent
+	for range 2 {
+		if _, err := outbox.Send(ctx, message("1", "unkeyed", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := outbox.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hello", "unkeyed", "unkeyed"}; !slices.Equal(discord.sent["1"], want) {
+		t.Errorf("sent %q, want %q", discord.sent["1"], want)
+	}
+}
+
+func TestOutboxRetries(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name     string
+		fail     func(attempt int, msg Message) error
+		attempts int
+		sent     bool
+	}{
+		{
+			name: "server errors until the third attempt",
+			fail: func(attempt int, _ Message) error {
+				if attempt < 3 {
+					return restError(http.StatusBadGateway)
+				}
+				return nil
+			},
+			attempts: 3,
+			sent:     true,
+		},
+		{
+			name:     "server errors on every attempt",
+			fail:     func(int, Message) error { return restError(http.StatusServiceUnavailable) },
+			attempts: outboxAttempts,
+		},
+		{
+			name:     "discordgo giving up on 502s",
+			fail:     func(int, Message) error { return errors.New("Exceeded Max retries HTTP 502 Bad Gateway") },
+			attempts: outboxAttempts,
+		},
+		{
+			name:     "client error",
+			fail:     func(int, Message) error { return restError(http.StatusForbidden) },
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			discord := &fakeDiscord{fail: tt.fail}
+			log := &memoryLog{}
+			outbox := newOutbox(discord.send, log)
+
+			_, err := outbox.Send(ctx, message("1", "hello", "greeting"))
+			if tt.sent && err != nil {
+				t.Errorf("Send returned %v", err)
+			} else if !tt.sent && err == nil {
+				t.Errorf("Send succeeded")
+			}
+			if discord.attempts != tt.attempts {
+				t.Errorf("made %d attempts, want %d", discord.attempts, tt.attempts)
+			}
+			if _, found, _ := log.MessageSent(ctx, "greeting"); found != tt.sent {
+				t.Errorf("message recorded: %v, want %v", found, tt.sent)
+			}
+		})
+	}
+}
+
+func TestOutboxSerializesChannels(t *testing.T) {
+	ctx := context.Background()
+	const perChannel = 20
+
+	var (
+		mu       sync.Mutex
+		inFlight = map[string]int{}
+		overlaps []string
+	)
+	released := make(chan struct{})
+	discord := &fakeDiscord{
+		sending: func(msg Message) {
+			mu.Lock()
+			inFlight[msg.ChannelID]++
+			if inFlight[msg.ChannelID] > 1 {
+				overlaps = append(overlaps, msg.ChannelID)
+			}
+			mu.Unlock()
+
+			// The first message of channel 1 waits on channel 2: a slow
+			// channel must not hold the others back
+			if msg.Send.Content == "1/0" {
+				select {
+				case <-released:
+				case <-time.After(5 * time.Second):
+					t.Error("channel 2 was held back by channel 1")
+				}
+			}
+			if msg.Send.Content == fmt.Sprintf("2/%d", perChannel-1) {
+				close(released)
+			}
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			inFlight[msg.ChannelID]--
+			mu.Unlock()
+		},
+	}
+	outbox := newOutbox(discord.send, &memoryLog{})
+
+	want := map[string][]string{}
+	for i := range perChannel {
+		for _, channel := range []string{"1", "2"} {
+			content := fmt.Sprintf("%s/%d", channel, i)
+			want[channel] = append(want[channel], content)
+			if err := outbox.Enqueue(ctx, message(channel, content, "")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := outbox.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(overlaps) > 0 {
+		t.Errorf("messages sent concurrently to channels %v", overlaps)
+	}
+	for channel, contents := range want {
+		if !slices.Equal(discord.sent[channel], contents) {
+			t.Errorf("channel %s received %q, want %q", channel, discord.sent[channel], contents)
+		}
+	}
+
+	if err := outbox.Enqueue(ctx, message("1", "late", "")); !errors.Is(err, ErrOutboxClosed) {
+		t.Errorf("Enqueue after Close returned %v, want ErrOutboxClosed", err)
+	}
+}
@@ -2,29 +2,46 @@ package discord
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
 	"github.com/ChausseBenjamin/swincebot/internal/secrets"
 	"github.com/bwmarrin/discordgo"
 )
 
+// tokenKey is the name of the bot token in the secrets vault
+const tokenKey = "discord_bot_token"
+
 type Client struct {
-	session   *discordgo.Session
-	serverID  string
-	channelID string
+	session  *discordgo.Session
+	token    string
+	rotating sync.Mutex // serializes token rotations, connections and Close
+	closed   atomic.Bool
 }
 
-func NewClient(ctx context.Context, serverID, channelID uint64, vault secrets.SecretVault) (*Client, error) {
-	token, err := vault.Get("discord_bot_token")
+// NewClient connects to Discord, failing right away if it can't
+func NewClient(ctx context.Context, vault secrets.SecretVault) (*Client, error) {
+	c, err := New(vault)
 	if err != nil {
-		return nil, fmt.Errorf("reading discord bot token: %w", err)
+		return nil, err
+	}
+	if err := c.open(); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
 
-	// Clean token of any whitespace/newlines
-	cleanToken := strings.TrimSpace(token.String())
-	if cleanToken == "" {
-		return nil, fmt.Errorf("discord bot token is empty")
+// New prepares a client without connecting it: see Connect
+func New(vault secrets.SecretVault) (*Client, error) {
+	cleanToken, err := readToken(vault)
+	if err != nil {
+		return nil, err
 	}
 
 	session, err := discordgo.New("Bot " + cleanToken)
@@ -32,31 +49,108 @@ func NewClient(ctx context.Context, serverID, channelID uint64, vault secrets.Se
 		return nil, fmt.Errorf("creating discord session: %w", err)
 	}
 
-	session.Identify.Intents = discordgo.IntentsGuildMembers | discordgo.IntentsGuilds
+	session.Identify.Intents = discordgo.IntentsGuildMembers | discordgo.IntentsGuilds | discordgo.IntentsDirectMessages
+	session.Client.Transport = tracedTransport{next: http.DefaultTransport}
 
-	if err := session.Open(); err != nil {
-		return nil, fmt.Errorf("opening discord session: %w", err)
+	c := &Client{
+		session: session,
+		token:   cleanToken,
 	}
+	c.monitorGateway()
+	return c, nil
+}
 
-	return &Client{
-		session:   session,
-		serverID:  fmt.Sprintf("%d", serverID),
-		channelID: fmt.Sprintf("%d", channelID),
-	}, nil
+// readToken fetches the bot token from the vault, cleaned of any whitespace/newlines
+func readToken(vault secrets.SecretVault) (string, error) {
+	token, err := vault.Get(tokenKey)
+	if err != nil {
+		return "", fmt.Errorf("reading discord bot token: %w", err)
+	}
+	cleanToken := strings.TrimSpace(token.String())
+	if cleanToken == "" {
+		return "", fmt.Errorf("discord bot token is empty")
+	}
+	return cleanToken, nil
 }
 
-func (c *Client) Close() error {
-	return c.session.Close()
+// WatchToken reconnects with the new bot token whenever it changes in the
+// vault. The session itself is kept, so registered handlers (and the
+// conversations they drive) carry on once the gateway is back.
+func (c *Client) WatchToken(ctx context.Context, vault secrets.SecretVault, changes <-chan string) {
+	for key := range changes {
+		if key != tokenKey {
+			continue
+		}
+		token, err := readToken(vault)
+		if err != nil {
+			slog.WarnContext(ctx, "Ignoring unusable discord bot token", logging.ErrKey, err)
+			continue
+		}
+		if err := c.Rotate(token); err != nil {
+			slog.ErrorContext(ctx, "Discord bot token rotation failed", logging.ErrKey, err)
+			continue
+		}
+	}
 }
 
-func (c *Client) Session() *discordgo.Session {
-	return c.session
+// Rotate reconnects the gateway using token. When the new token is rejected,
+// the previous one is restored so the bot stays online.
+func (c *Client) Rotate(token string) error {
+	c.rotating.Lock()
+	defer c.rotating.Unlock()
+
+	if token == c.token {
+		return nil
+	}
+	if c.closed.Load() {
+		return errors.New("discord client is closed")
+	}
+
+	if err := c.reconnect(token); err != nil {
+		if restoreErr := c.reconnect(c.token); restoreErr != nil {
+			return fmt.Errorf("%w (restoring previous token: %v)", err, restoreErr)
+		}
+		return err
+	}
+
+	c.token = token
+	slog.Info("Discord bot token rotated")
+	return nil
 }
 
-func (c *Client) ServerID() string {
-	return c.serverID
+// reconnect closes the gateway connection and opens it again as token
+func (c *Client) reconnect(token string) error {
+	if err := c.session.Close(); err != nil {
+		return fmt.Errorf("closing discord session: %w", err)
+	}
+
+	c.session.Lock()
+	c.session.Token = "Bot " + token
+	c.session.Identify.Token = c.session.Token
+	c.session.Unlock()
+
+	if err := c.session.Open(); err != nil {
+		return fmt.Errorf("opening discord session: %w", err)
+	}
+	return nil
 }
 
-func (c *Client) ChannelID() string {
-	return c.channelID
+// Connected reports whether the gateway connection is up and answering
+// heartbeats
+func (c *Client) Connected() bool {
+	c.session.RLock()
+	defer c.session.RUnlock()
+	return c.session.DataReady
+}
+
+// Close disconnects the gateway and stops Connect from retrying
+func (c *Client) Close() error {
+	c.rotating.Lock()
+	defer c.rotating.Unlock()
+	c.closed.Store(true)
+	return c.session.Close()
+}
+
+func (c *Client) Session() *discordgo.Session {
+	return c.session
 }
@@ -0,0 +1,51 @@
+package discord
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/tracing"
+)
+
+// tracedTransport opens a span around every Discord REST call. Calls made
+// with discordgo.WithContext become children of the caller's span.
+type tracedTransport struct {
+	next http.RoundTripper
+}
+
+func (t tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := restRoute(req.URL.Path)
+	ctx, span := tracing.Start(req.Context(), tracing.KindClient, "discord "+req.Method+" "+route,
+		slog.String("http.method", req.Method),
+		slog.String("http.route", route),
+	)
+	defer span.End()
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttrs(slog.Int("http.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.RecordError(fmt.Errorf("discord answered %s", resp.Status))
+	}
+	return resp, nil
+}
+
+// restRoute turns a request path into a template: IDs would make every span
+// name unique and interaction/webhook tokens are credentials.
+func restRoute(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if _, err := strconv.ParseUint(part, 10, 64); err == nil {
+			parts[i] = "{id}"
+		} else if i >= 2 && (parts[i-2] == "interactions" || parts[i-2] == "webhooks") {
+			parts[i] = "{token}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -1,19 +1,25 @@
 package discord
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
+
+	"github.com/bwmarrin/discordgo"
 )
 
 type User struct {
 	ID uint64
 	// Nickname in this specific server (not generic @)
 	Nick string
+	// Account-wide username (the generic @)
+	Username string
 }
 
-func (c *Client) GetNick(userID uint64) (string, error) {
-	member, err := c.session.GuildMember(c.serverID, fmt.Sprintf("%d", userID))
+func (c *Client) GetNick(guildID, userID uint64) (string, error) {
+	member, err := c.session.GuildMember(strconv.FormatUint(guildID, 10), strconv.FormatUint(userID, 10))
 	if err != nil {
 		return "", fmt.Errorf("getting guild member: %w", err)
 	}
@@ -24,8 +30,20 @@ func (c *Client) GetNick(userID uint64) (string, error) {
 	return member.User.Username, nil
 }
 
-func (c *Client) GetMembers() ([]User, error) {
-	members, err := c.session.GuildMembers(c.serverID, "", 1000)
+// IsMember reports whether a user belongs to a guild
+func (c *Client) IsMember(ctx context.Context, guildID, userID uint64) (bool, error) {
+	_, err := c.session.GuildMember(strconv.FormatUint(guildID, 10), strconv.FormatUint(userID, 10), discordgo.WithContext(ctx))
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMember {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("getting guild member: %w", err)
+	}
+	return true, nil
+}
+
+func (c *Client) GetMembers(guildID uint64) ([]User, error) {
+	members, err := c.session.GuildMembers(strconv.FormatUint(guildID, 10), "", 1000)
 	if err != nil {
 		return nil, fmt.Errorf("getting guild members: %w", err)
 	}
@@ -48,8 +66,9 @@ func (c *Client) GetMembers() ([]User, error) {
 		}
 
 		users = append(users, User{
-			ID:   userID,
-			Nick: nick,
+			ID:       userID,
+			Nick:     nick,
+			Username: member.User.Username,
 		})
 	}
 
@@ -0,0 +1,83 @@
+// Package lifecycle starts the long-lived components of the bot in order and
+// stops them in reverse order, so nothing gets used after what it depends on
+// was closed.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+// Hook starts or stops a component. Both are optional.
+type Hook func(ctx context.Context) error
+
+type Component struct {
+	Name  string
+	Start Hook
+	Stop  Hook
+}
+
+// Manager runs components in the order they were added. It isn't safe for
+// concurrent use: Stop must only be called once Start returned.
+type Manager struct {
+	components []Component
+	started    int // components whose Start succeeded
+}
+
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Start starts every component, giving up at the first failure or once ctx
+// is cancelled. Components started until then are left running: call Stop.
+func (m *Manager) Start(ctx context.Context) error {
+	for _, c := range m.components[m.started:] {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("starting %s: %w", c.Name, err)
+		}
+		if c.Start != nil {
+			begin := time.Now()
+			if err := c.Start(ctx); err != nil {
+				return fmt.Errorf("starting %s: %w", c.Name, err)
+			}
+			slog.DebugContext(ctx, "Component started", "component", c.Name, "took", time.Since(begin))
+		}
+		m.started++
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order. Once ctx expires,
+// the remaining hooks are still called (so files and connections get closed)
+// but no longer waited for.
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for ; m.started > 0; m.started-- {
+		c := m.components[m.started-1]
+		if c.Stop == nil {
+			continue
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- c.Stop(ctx) }()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Component did not stop cleanly", logging.ErrKey, err, "component", c.Name)
+			errs = append(errs, fmt.Errorf("stopping %s: %w", c.Name, err))
+			continue
+		}
+		slog.DebugContext(ctx, "Component stopped", "component", c.Name)
+	}
+	return errors.Join(errs...)
+}
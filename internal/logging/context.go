@@ -3,6 +3,8 @@ package logging
 import (
 	"context"
 	"log/slog"
+
+	"github.com/ChausseBenjamin/swincebot/internal/util"
 )
 
 type ctxTracker struct {
@@ -23,11 +25,13 @@ func (h ctxTracker) Enabled(ctx context.Context, lvl slog.Level) bool {
 }
 
 func (h ctxTracker) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return h.next.WithAttrs(attrs)
+	h.next = h.next.WithAttrs(attrs)
+	return h
 }
 
 func (h ctxTracker) WithGroup(name string) slog.Handler {
-	return h.next.WithGroup(name)
+	h.next = h.next.WithGroup(name)
+	return h
 }
 
 func withTrackedContext(current slog.Handler, ctxKey any, logKey string) *ctxTracker {
@@ -37,3 +41,34 @@ func withTrackedContext(current slog.Handler, ctxKey any, logKey string) *ctxTra
 		next:   current,
 	}
 }
+
+// spanContext is implemented by tracing spans. Logging only needs their IDs,
+// so it doesn't have to depend on the tracing package.
+type spanContext interface {
+	IDs() (traceID, spanID string)
+}
+
+// spanTracker adds the IDs of the current span to records
+type spanTracker struct {
+	next slog.Handler
+}
+
+func (h spanTracker) Handle(ctx context.Context, r slog.Record) error {
+	if span, ok := ctx.Value(util.SpanKey).(spanContext); ok {
+		traceID, spanID := span.IDs()
+		r.AddAttrs(slog.String("trace_id", traceID), slog.String("span_id", spanID))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h spanTracker) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+func (h spanTracker) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return spanTracker{next: h.next.WithAttrs(attrs)}
+}
+
+func (h spanTracker) WithGroup(name string) slog.Handler {
+	return spanTracker{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// fanout sends records to every handler enabled for their level
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, lvl slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make(fanout, len(f))
+	for i, h := range f {
+		next[i] = h.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	next := make(fanout, len(f))
+	for i, h := range f {
+		next[i] = h.WithGroup(name)
+	}
+	return next
+}
+
+// levelWriter is an output which needs the level of the records written to
+// it (ex: syslog)
+type levelWriter interface {
+	WriteLevel(level slog.Level, p []byte) (int, error)
+}
+
+// levelSink passes the level of the record being handled to a levelWriter
+type levelSink struct {
+	mu    sync.Mutex
+	level slog.Level
+	w     levelWriter
+}
+
+func (s *levelSink) Write(p []byte) (int, error) {
+	return s.w.WriteLevel(s.level, p)
+}
+
+// leveled lets the handler writing to sink tell it the level of each record.
+// Formatting handlers write each record in a single call, while sink is locked.
+type leveled struct {
+	next slog.Handler
+	sink *levelSink
+}
+
+func (h leveled) Handle(ctx context.Context, r slog.Record) error {
+	h.sink.mu.Lock()
+	defer h.sink.mu.Unlock()
+	h.sink.level = r.Level
+	return h.next.Handle(ctx, r)
+}
+
+func (h leveled) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return h.next.Enabled(ctx, lvl)
+}
+
+func (h leveled) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return leveled{next: h.next.WithAttrs(attrs), sink: h.sink}
+}
+
+func (h leveled) WithGroup(name string) slog.Handler {
+	return leveled{next: h.next.WithGroup(name), sink: h.sink}
+}
@@ -0,0 +1,202 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// subsystemKey is added to the records of subsystem loggers. An attribute is
+// used rather than a logger group: a group would also nest the attributes
+// added further down the handler chain (request_id, trace_id and
+// error_message) under the subsystem's name, so the same request would be
+// searched differently depending on who logged it and error reports would
+// lose their trace and fingerprint.
+const subsystemKey = "subsystem"
+
+var ErrUnknownSubsystem = errors.New("unknown subsystem")
+
+var (
+	level        slog.LevelVar // global level, set by Setup
+	initialLevel slog.Level
+
+	subsystemsMu sync.RWMutex
+	subsystems   = make(map[string]*slog.LevelVar) // nil until overridden
+)
+
+// Subsystem returns a logger whose level can be changed apart from the global
+// one, tagging its records with the subsystem's name. It may be called before
+// Setup: records go to the default logger of the time they're written.
+// Packages declare theirs at init, so every name is known once main starts.
+func Subsystem(name string) *slog.Logger {
+	subsystemsMu.Lock()
+	defer subsystemsMu.Unlock()
+	if _, exists := subsystems[name]; !exists {
+		subsystems[name] = nil
+	}
+	return slog.New(subsystemHandler{name: name})
+}
+
+// Subsystems lists the names accepted by SetLevel
+func Subsystems() []string {
+	subsystemsMu.RLock()
+	defer subsystemsMu.RUnlock()
+	return slices.Sorted(maps.Keys(subsystems))
+}
+
+// ParseLevel reads debug, info, warn or error (prefixes work too)
+func ParseLevel(s string) (slog.Level, error) {
+	lvl, err := setLevel(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", err, s)
+	}
+	return slog.Level(lvl), nil
+}
+
+// SetLevel changes the level of a subsystem, or the global one when
+// subsystem is empty
+func SetLevel(subsystem string, lvl slog.Level) error {
+	if subsystem == "" {
+		level.Set(lvl)
+		return nil
+	}
+
+	subsystemsMu.Lock()
+	defer subsystemsMu.Unlock()
+	v, exists := subsystems[subsystem]
+	if !exists {
+		return fmt.Errorf("%w %q (expected one of %v)", ErrUnknownSubsystem, subsystem, slices.Sorted(maps.Keys(subsystems)))
+	}
+	if v == nil {
+		v = new(slog.LevelVar)
+		subsystems[subsystem] = v
+	}
+	v.Set(lvl)
+	return nil
+}
+
+// ResetLevel makes a subsystem follow the global level again, or restores the
+// global level given to Setup when subsystem is empty
+func ResetLevel(subsystem string) error {
+	if subsystem == "" {
+		level.Set(initialLevel)
+		return nil
+	}
+
+	subsystemsMu.Lock()
+	defer subsystemsMu.Unlock()
+	if _, exists := subsystems[subsystem]; !exists {
+		return fmt.Errorf("%w %q", ErrUnknownSubsystem, subsystem)
+	}
+	subsystems[subsystem] = nil
+	return nil
+}
+
+// LevelReset, given to ChangeLevel, undoes previous changes
+const LevelReset = "reset"
+
+// ChangeLevel sets a subsystem (or the global level when empty) to a level
+// name, or resets it
+func ChangeLevel(subsystem, level string) error {
+	if level == LevelReset {
+		return ResetLevel(subsystem)
+	}
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	return SetLevel(subsystem, lvl)
+}
+
+// StepLevel makes the global level more (negative steps) or less verbose,
+// staying between debug and error
+func StepLevel(steps int) slog.Level {
+	lvl := level.Level() + slog.Level(4*steps)
+	lvl = min(max(lvl, slog.LevelDebug), slog.LevelError)
+	level.Set(lvl)
+	return lvl
+}
+
+// LevelState is the global level and the subsystems overriding it
+type LevelState struct {
+	Global     slog.Level            `json:"global"`
+	Subsystems map[string]slog.Level `json:"subsystems"`
+}
+
+func Levels() LevelState {
+	subsystemsMu.RLock()
+	defer subsystemsMu.RUnlock()
+	state := LevelState{Global: level.Level(), Subsystems: make(map[string]slog.Level)}
+	for name, v := range subsystems {
+		if v != nil {
+			state.Subsystems[name] = v.Level()
+		}
+	}
+	return state
+}
+
+func subsystemLevel(name string) slog.Level {
+	subsystemsMu.RLock()
+	v := subsystems[name]
+	subsystemsMu.RUnlock()
+	if v == nil {
+		return level.Level()
+	}
+	return v.Level()
+}
+
+// levelFilter drops records below the global level. Subsystem loggers bypass
+// it: they check their own level.
+type levelFilter struct {
+	next slog.Handler
+}
+
+func (h levelFilter) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= level.Level() && h.next.Enabled(ctx, lvl)
+}
+
+func (h levelFilter) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h levelFilter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelFilter{next: h.next.WithAttrs(attrs)}
+}
+
+func (h levelFilter) WithGroup(name string) slog.Handler {
+	return levelFilter{next: h.next.WithGroup(name)}
+}
+
+// subsystemHandler hands records to the default handler once they pass the
+// subsystem's level
+type subsystemHandler struct {
+	name string
+	with []func(slog.Handler) slog.Handler // WithAttrs/WithGroup calls to replay
+}
+
+func (h subsystemHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= subsystemLevel(h.name)
+}
+
+func (h subsystemHandler) Handle(ctx context.Context, r slog.Record) error {
+	next := slog.Default().Handler()
+	for _, with := range h.with {
+		next = with(next)
+	}
+	r.AddAttrs(slog.String(subsystemKey, h.name))
+	return next.Handle(ctx, r)
+}
+
+func (h subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.with = append(slices.Clip(h.with), func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+	return h
+}
+
+func (h subsystemHandler) WithGroup(name string) slog.Handler {
+	h.with = append(slices.Clip(h.with), func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+	return h
+}
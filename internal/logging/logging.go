@@ -23,37 +23,83 @@ var (
 
 const DisableLogs log.Formatter = 255
 
-func Setup(lvlStr, fmtStr, outStr string) error {
-	output, outputErr := setOutput(outStr)
-	format, formatErr := setFormat(fmtStr)
-	level, levelErr := setLevel(lvlStr)
+// Config describes where logs go
+type Config struct {
+	Level    string
+	Format   string   // used by outputs which don't pick one
+	Outputs  []string // see output
+	Rotation Rotation
+}
 
-	prefixStr := ""
-	if format != log.JSONFormatter {
-		prefixStr = "SwinceBot 🍺"
+func Setup(cfg Config) error {
+	format, formatErr := setFormat(cfg.Format)
+	lvl, levelErr := setLevel(cfg.Level)
+	errs := []error{formatErr, levelErr}
+	initialLevel = slog.Level(lvl)
+	level.Set(initialLevel)
+
+	var outputs fanout
+	for _, spec := range cfg.Outputs {
+		out, err := parseOutput(spec, format)
+		if err == nil && out.format != DisableLogs {
+			var w io.Writer
+			if w, err = out.open(cfg.Rotation); err == nil {
+				outputs = append(outputs, newHandler(w, out.format))
+			}
+		}
+		errs = append(errs, err)
 	}
 
 	var h slog.Handler
-	if format == DisableLogs {
+	switch {
+	case len(outputs) > 0:
+		h = outputs
+	case errors.Join(errs...) != nil:
+		// Don't go silent because of a typo
+		h = newHandler(os.Stdout, format)
+	default:
 		h = DiscardHandler{}
-	} else {
-		h = log.NewWithOptions(
-			output,
-			log.Options{
-				TimeFormat:   time.DateTime,
-				Prefix:       prefixStr,
-				Level:        level,
-				ReportCaller: true,
-				Formatter:    format,
-			},
-		)
-
+	}
+	if _, discard := h.(DiscardHandler); !discard {
+		h = fanout{h, reportHandler{}}
 		h = withTrackedContext(h, util.ReqIDKey, "request_id")
+		h = spanTracker{next: h}
 		h = withStackTrace(h)
+		h = levelFilter{next: h}
 	}
 
 	slog.SetDefault(slog.New(h))
-	return errors.Join(outputErr, formatErr, levelErr)
+	return errors.Join(errs...)
+}
+
+// newHandler formats records to w. Levels are filtered beforehand, by
+// levelFilter or subsystem loggers.
+func newHandler(w io.Writer, format log.Formatter) slog.Handler {
+	prefixStr := ""
+	if format != log.JSONFormatter {
+		prefixStr = "SwinceBot 🍺"
+	}
+
+	var sink *levelSink
+	if lw, ok := w.(levelWriter); ok {
+		sink = &levelSink{w: lw}
+		w = sink
+	}
+
+	h := log.NewWithOptions(
+		w,
+		log.Options{
+			TimeFormat:   time.DateTime,
+			Prefix:       prefixStr,
+			Level:        log.DebugLevel,
+			ReportCaller: true,
+			Formatter:    format,
+		},
+	)
+	if sink != nil {
+		return leveled{next: h, sink: sink}
+	}
+	return h
 }
 
 func setLevel(target string) (log.Level, error) {
@@ -84,19 +130,3 @@ func setFormat(f string) (log.Formatter, error) {
 	}
 	return log.TextFormatter, ErrInvalidFormat
 }
-
-func setOutput(path string) (io.Writer, error) {
-	switch path {
-	case "stdout":
-		return os.Stdout, nil
-	case "stderr":
-		return os.Stderr, nil
-	default:
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return os.Stdout, err
-		} else {
-			return f, nil
-		}
-	}
-}
@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Rotation applies to every file output
+type Rotation struct {
+	MaxSize    int           // megabytes a file may reach before it's rotated
+	Interval   time.Duration // rotate files this often, 0 only rotates by size
+	MaxBackups int           // rotated files kept, 0 keeps them all
+	MaxAge     time.Duration // rotated files older than this are deleted, 0 keeps them
+	Compress   bool          // gzip rotated files
+}
+
+// output is where logs go, in which format. Specs look like "[format:]target"
+// where the target is stdout, stderr, journald, syslog[+tcp]://host:port, a
+// local syslog or a file path.
+type output struct {
+	format log.Formatter
+	target string
+}
+
+func parseOutput(spec string, defaultFormat log.Formatter) (output, error) {
+	out := output{format: defaultFormat, target: spec}
+	if prefix, target, ok := strings.Cut(spec, ":"); ok {
+		if format, err := setFormat(prefix); err == nil {
+			out.format, out.target = format, target
+		}
+	}
+	if out.target == "" {
+		return out, fmt.Errorf("log output %q has no target", spec)
+	}
+	return out, nil
+}
+
+// ValidateOutput checks an output spec is usable (files must be writable)
+func ValidateOutput(spec string) error {
+	out, err := parseOutput(spec, log.TextFormatter)
+	if err != nil {
+		return err
+	}
+	switch {
+	case out.target == "stdout", out.target == "stderr", out.target == "journald":
+		return nil
+	case isSyslog(out.target):
+		_, _, err := syslogAddr(out.target)
+		return err
+	}
+	f, err := os.OpenFile(out.target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("creating/accessing log file %s: %w", out.target, err)
+	}
+	return f.Close()
+}
+
+// open returns the writer of the output. Syslog and journald writers also
+// receive the level of each record.
+func (o output) open(rot Rotation) (io.Writer, error) {
+	switch {
+	case o.target == "stdout":
+		return os.Stdout, nil
+	case o.target == "stderr":
+		return os.Stderr, nil
+	case o.target == "journald":
+		w, err := dialJournald()
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	case isSyslog(o.target):
+		w, err := dialSyslog(o.target)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
+	// Fail now rather than on the first write
+	f, err := os.OpenFile(o.target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file %s: %w", o.target, err)
+	}
+	f.Close()
+
+	file := &lumberjack.Logger{
+		Filename:   o.target,
+		MaxSize:    rot.MaxSize,
+		MaxBackups: rot.MaxBackups,
+		MaxAge:     int((rot.MaxAge + 24*time.Hour - 1) / (24 * time.Hour)), // days, rounded up
+		Compress:   rot.Compress,
+		LocalTime:  true,
+	}
+	if rot.Interval > 0 {
+		go func() {
+			for range time.Tick(rot.Interval) {
+				file.Rotate() //nolint:errcheck
+			}
+		}()
+	}
+	return file, nil
+}
+
+func isSyslog(target string) bool {
+	return target == "syslog" || strings.HasPrefix(target, "syslog://") || strings.HasPrefix(target, "syslog+tcp://")
+}
+
+// syslogAddr returns where to dial a syslog target, empty for the local daemon
+func syslogAddr(target string) (network, addr string, err error) {
+	switch {
+	case target == "syslog":
+		return "", "", nil
+	case strings.HasPrefix(target, "syslog+tcp://"):
+		network, addr = "tcp", strings.TrimPrefix(target, "syslog+tcp://")
+	default:
+		network, addr = "udp", strings.TrimPrefix(target, "syslog://")
+	}
+	if !strings.Contains(addr, ":") {
+		return "", "", fmt.Errorf("syslog output %q needs a host:port", target)
+	}
+	return network, addr, nil
+}
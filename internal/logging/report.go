@@ -0,0 +1,226 @@
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceKey is the attribute holding the stack trace added by stackTracer
+const traceKey = "trace"
+
+// Report describes an error logged Count times between First and Last, all
+// sharing a Fingerprint. Message, Attrs and Trace come from the latest one.
+type Report struct {
+	Fingerprint string
+	Message     string
+	Source      string // file:line which logged the error
+	Attrs       []slog.Attr
+	Trace       string
+	Count       int
+	First, Last time.Time
+}
+
+// ReportFunc hands a report to a human. It must not block: reports are sent
+// while the error gets logged.
+type ReportFunc func(ctx context.Context, r Report) error
+
+// reporter groups the errors it receives by fingerprint, sending the first
+// of a group right away and a summary of the repeats at most once per
+// interval
+type reporter struct {
+	mu       sync.Mutex
+	send     ReportFunc // nil when reports are off
+	interval time.Duration
+	groups   map[string]*reportGroup
+}
+
+type reportGroup struct {
+	latest  Report // Count only holds the occurrences not sent yet
+	sentAt  time.Time
+	pending bool
+}
+
+var reports = &reporter{groups: make(map[string]*reportGroup)}
+
+type noReportKey struct{}
+
+// WithoutReports marks ctx so errors logged with it aren't reported, which
+// keeps the sending of reports from reporting its own failures
+func WithoutReports(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noReportKey{}, true)
+}
+
+// ReportErrors sends the errors logged from now on to send, repeats of an
+// error being sent at most once per interval. The returned function stops
+// the reports, sending the repeats still pending.
+func ReportErrors(send ReportFunc, interval time.Duration) (stop func()) {
+	reports.mu.Lock()
+	reports.send, reports.interval = send, interval
+	reports.mu.Unlock()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				reports.flush(now, false)
+			case <-done:
+				reports.flush(time.Now(), true)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		reports.mu.Lock()
+		reports.send = nil
+		reports.mu.Unlock()
+	}
+}
+
+// record counts r in its group, returning the report to send now if any
+func (rep *reporter) record(r Report) (ReportFunc, *Report) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.send == nil {
+		return nil, nil
+	}
+
+	g, exists := rep.groups[r.Fingerprint]
+	if !exists {
+		g = &reportGroup{}
+		rep.groups[r.Fingerprint] = g
+	}
+	if !g.pending {
+		r.Count, r.First = 0, r.Last
+	} else {
+		r.Count, r.First = g.latest.Count, g.latest.First
+	}
+	r.Count++
+	g.latest, g.pending = r, true
+
+	if exists && r.Last.Sub(g.sentAt) < rep.interval {
+		return nil, nil
+	}
+	g.sentAt, g.pending = r.Last, false
+	return rep.send, &r
+}
+
+// flush sends the repeats held back for an interval (all of them when
+// final) and forgets the groups which stayed quiet for as long
+func (rep *reporter) flush(now time.Time, final bool) {
+	rep.mu.Lock()
+	send := rep.send
+	var due []Report
+	for fingerprint, g := range rep.groups {
+		switch {
+		case g.pending && (final || now.Sub(g.sentAt) >= rep.interval):
+			due = append(due, g.latest)
+			g.sentAt, g.pending = now, false
+		case !g.pending && now.Sub(g.sentAt) >= rep.interval:
+			delete(rep.groups, fingerprint)
+		}
+	}
+	rep.mu.Unlock()
+
+	if send == nil {
+		return
+	}
+	ctx := WithoutReports(context.Background())
+	for _, r := range due {
+		if err := send(ctx, r); err != nil {
+			slog.WarnContext(ctx, "Unable to report error", ErrKey, err, "fingerprint", r.Fingerprint)
+		}
+	}
+}
+
+// reportHandler feeds the error records it handles to the reporter
+type reportHandler struct {
+	attrs  []slog.Attr
+	groups string // prefix of the keys of attributes added later
+}
+
+func (h reportHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= slog.LevelError && ctx.Value(noReportKey{}) == nil
+}
+
+func (h reportHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.Enabled(ctx, r.Level) {
+		return nil
+	}
+
+	report := Report{
+		Message: r.Message,
+		Attrs:   h.attrs,
+		Last:    r.Time,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		report.Source = fmt.Sprintf("%s:%d", frame.File, frame.Line)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == traceKey && h.groups == "" {
+			report.Trace = a.Value.String()
+		} else {
+			report.Attrs = append(report.Attrs[:len(report.Attrs):len(report.Attrs)], h.prefixed(a))
+		}
+		return true
+	})
+	report.Fingerprint = fingerprint(report)
+
+	send, due := reports.record(report)
+	if due == nil {
+		return nil
+	}
+	if err := send(WithoutReports(ctx), *due); err != nil {
+		return fmt.Errorf("reporting error: %w", err)
+	}
+	return nil
+}
+
+func (h reportHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(next, h.attrs)
+	for _, a := range attrs {
+		next = append(next, h.prefixed(a))
+	}
+	h.attrs = next
+	return h
+}
+
+func (h reportHandler) WithGroup(name string) slog.Handler {
+	h.groups += name + "."
+	return h
+}
+
+func (h reportHandler) prefixed(a slog.Attr) slog.Attr {
+	a.Key = h.groups + a.Key
+	return a
+}
+
+// fingerprint identifies errors logged by the same line for the same reason.
+// Attributes other than the error are left out as they usually hold IDs.
+func fingerprint(r Report) string {
+	var errMsg string
+	for _, a := range r.Attrs {
+		if a.Key == ErrKey {
+			errMsg = a.Value.String()
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{r.Source, r.Message, errMsg}, "\x00")))
+	return hex.EncodeToString(sum[:6])
+}
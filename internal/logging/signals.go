@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// StepOnSignals makes the global level more verbose on SIGUSR1 and less on
+// SIGUSR2, until ctx is done
+func StepOnSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case sig := <-signals:
+			steps := 1
+			if sig == syscall.SIGUSR1 {
+				steps = -1
+			}
+			// Warn so the change shows up at any level but error
+			slog.WarnContext(ctx, "Log level changed", "new_level", StepLevel(steps), "signal", sig.String())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
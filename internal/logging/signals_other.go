@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package logging
+
+import "context"
+
+// StepOnSignals does nothing: SIGUSR1 and SIGUSR2 don't exist here
+func StepOnSignals(context.Context) {}
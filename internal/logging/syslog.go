@@ -0,0 +1,111 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"strconv"
+)
+
+const (
+	syslogTag     = "swincebot"
+	journalSocket = "/run/systemd/journal/socket"
+)
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func dialSyslog(target string) (*syslogWriter, error) {
+	network, addr, err := syslogAddr(target)
+	if err != nil {
+		return nil, err
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	return s.WriteLevel(slog.LevelInfo, p)
+}
+
+func (s *syslogWriter) WriteLevel(level slog.Level, p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	var err error
+	switch {
+	case level >= slog.LevelError:
+		err = s.w.Err(msg)
+	case level >= slog.LevelWarn:
+		err = s.w.Warning(msg)
+	case level >= slog.LevelInfo:
+		err = s.w.Info(msg)
+	default:
+		err = s.w.Debug(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// journald speaks the native journal protocol, which keeps the priority of
+// each entry
+type journald struct {
+	conn *net.UnixConn
+}
+
+func dialJournald() (*journald, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to journald: %w", err)
+	}
+	return &journald{conn: conn}, nil
+}
+
+func (j *journald) Write(p []byte) (int, error) {
+	return j.WriteLevel(slog.LevelInfo, p)
+}
+
+func (j *journald) WriteLevel(level slog.Level, p []byte) (int, error) {
+	priority := syslog.LOG_DEBUG
+	switch {
+	case level >= slog.LevelError:
+		priority = syslog.LOG_ERR
+	case level >= slog.LevelWarn:
+		priority = syslog.LOG_WARNING
+	case level >= slog.LevelInfo:
+		priority = syslog.LOG_INFO
+	}
+
+	var entry bytes.Buffer
+	journalField(&entry, "PRIORITY", []byte(strconv.Itoa(int(priority))))
+	journalField(&entry, "SYSLOG_IDENTIFIER", []byte(syslogTag))
+	journalField(&entry, "MESSAGE", bytes.TrimRight(p, "\n"))
+	if _, err := j.conn.Write(entry.Bytes()); err != nil {
+		return 0, fmt.Errorf("writing to journald: %w", err)
+	}
+	return len(p), nil
+}
+
+// journalField appends a field to an entry. Values spanning several lines
+// are prefixed by their length instead.
+func journalField(entry *bytes.Buffer, key string, value []byte) {
+	entry.WriteString(key)
+	if bytes.IndexByte(value, '\n') < 0 {
+		entry.WriteByte('=')
+		entry.Write(value)
+	} else {
+		entry.WriteByte('\n')
+		binary.Write(entry, binary.LittleEndian, uint64(len(value))) //nolint:errcheck
+		entry.Write(value)
+	}
+	entry.WriteByte('\n')
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"io"
+)
+
+var errNoSyslog = errors.New("syslog and journald outputs aren't supported on this platform")
+
+func dialSyslog(string) (io.Writer, error) { return nil, errNoSyslog }
+func dialJournald() (io.Writer, error)     { return nil, errNoSyslog }
@@ -10,7 +10,7 @@ import (
 
 const (
 	prgCount = 20
-	defSkip  = 6
+	defSkip  = 2 // runtime.Callers and GetTrace
 )
 
 type stackTracer struct {
@@ -23,11 +23,13 @@ func (h stackTracer) Enabled(ctx context.Context, lvl slog.Level) bool {
 }
 
 func (h stackTracer) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return h.h.WithAttrs(attrs)
+	h.h = h.h.WithAttrs(attrs)
+	return h
 }
 
 func (h stackTracer) WithGroup(name string) slog.Handler {
-	return h.h.WithGroup(name)
+	h.h = h.h.WithGroup(name)
+	return h
 }
 
 func (h stackTracer) Handle(ctx context.Context, r slog.Record) error {
@@ -36,7 +38,7 @@ func (h stackTracer) Handle(ctx context.Context, r slog.Record) error {
 	}
 
 	trace := h.GetTrace()
-	r.AddAttrs(slog.String("trace", trace))
+	r.AddAttrs(slog.String(traceKey, trace))
 
 	return h.h.Handle(ctx, r)
 }
@@ -48,11 +50,20 @@ func (h stackTracer) GetTrace() string {
 	frames := runtime.CallersFrames(pc[:n])
 
 	for frame, more := frames.Next(); more; frame, more = frames.Next() {
+		// The depth of the handler chain varies: skip it along with slog
+		if b.Len() == 0 && loggingFrame(frame.Function) {
+			continue
+		}
 		b.WriteString(frame.Function + "\n    " + frame.File + ":" + strconv.Itoa(frame.Line) + "\n")
 	}
 	return b.String()
 }
 
+func loggingFrame(function string) bool {
+	return strings.HasPrefix(function, "log/slog.") ||
+		strings.HasPrefix(function, "github.com/ChausseBenjamin/swincebot/internal/logging.")
+}
+
 func withStackTrace(h slog.Handler) slog.Handler {
 	return stackTracer{
 		h:     h,
@@ -0,0 +1,306 @@
+// Package metrics is a minimal implementation of the Prometheus text
+// exposition format: just enough counters, histograms and gauges to watch the
+// bot without pulling the whole client library.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+// collector is a metric family able to write itself in the text format
+type collector interface {
+	write(ctx context.Context, w io.Writer) error
+}
+
+var registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func register[C collector](c C) C {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.collectors = append(registry.collectors, c)
+	return c
+}
+
+// Handler serves every registered metric. A gauge failing to collect is
+// left out of the response rather than failing the whole scrape.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry.mu.Lock()
+		collectors := slices.Clone(registry.collectors)
+		registry.mu.Unlock()
+
+		var buf bytes.Buffer
+		for _, c := range collectors {
+			if err := c.write(r.Context(), &buf); err != nil {
+				slog.WarnContext(r.Context(), "Failed to collect metric", logging.ErrKey, err)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes()) //nolint:errcheck
+	})
+}
+
+// family holds what every kind of metric shares
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	mu     sync.Mutex
+}
+
+func (f *family) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+}
+
+// key identifies a series by its label values
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s expects labels %v, got %d values", f.name, f.labels, len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats label names and values, with optional extra pairs
+// (ex: the le label of histogram buckets)
+func (f *family) labelPairs(values []string, extra ...string) string {
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, f.labels[i], escapeLabel(v)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter only goes up
+type Counter struct {
+	family
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels []string
+	value  float64
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return register(&Counter{
+		family: family{name: name, help: help, kind: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	})
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labels: slices.Clone(labelValues)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) write(_ context.Context, w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w)
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(s.labels), formatFloat(s.value))
+	}
+	return nil
+}
+
+// DurationBuckets suit most request latencies (seconds)
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	family
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // per bucket, the last one being +Inf
+	sum    float64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return register(&Histogram{
+		family:  family{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	})
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	idx, _ := slices.BinarySearch(h.buckets, v)
+	s.counts[idx]++
+	s.sum += v
+}
+
+// Since observes the time elapsed since start, in seconds
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(_ context.Context, w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(s.labels, "le", formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(s.labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(s.labels), cumulative)
+	}
+	return nil
+}
+
+// Gauge goes up and down
+type Gauge struct {
+	family
+	series map[string]*counterSeries
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return register(&Gauge{
+		family: family{name: name, help: help, kind: "gauge", labels: labels},
+		series: make(map[string]*counterSeries),
+	})
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.series[key]
+	if !ok {
+		s = &counterSeries{labels: slices.Clone(labelValues)}
+		g.series[key] = s
+	}
+	s.value += v
+}
+
+func (g *Gauge) write(_ context.Context, w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w)
+	for _, key := range sortedKeys(g.series) {
+		s := g.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(s.labels), formatFloat(s.value))
+	}
+	return nil
+}
+
+// Sample is one series of a GaugeFunc
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc computes its series when scraped
+type GaugeFunc struct {
+	family
+	collect func(context.Context) ([]Sample, error)
+}
+
+func NewGaugeFunc(name, help string, collect func(context.Context) ([]Sample, error), labels ...string) *GaugeFunc {
+	return register(&GaugeFunc{
+		family:  family{name: name, help: help, kind: "gauge", labels: labels},
+		collect: collect,
+	})
+}
+
+func (g *GaugeFunc) write(ctx context.Context, w io.Writer) error {
+	samples, err := g.collect(ctx)
+	if err != nil {
+		return fmt.Errorf("collecting %s: %w", g.name, err)
+	}
+
+	g.header(w)
+	for _, s := range samples {
+		g.key(s.Labels) // validates the label count
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(s.Labels), formatFloat(s.Value))
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,55 @@
+package metrics
+
+// Outbound message outcomes
+const (
+	OutboxSent      = "sent"
+	OutboxDuplicate = "duplicate" // already sent under the same idempotency key
+	OutboxFailed    = "failed"
+)
+
+// Conversation outcomes
+const (
+	ConversationCompleted   = "completed"
+	ConversationCancelled   = "cancelled"
+	ConversationTimedOut    = "timed_out"
+	ConversationFailed      = "failed"      // the submission couldn't be saved
+	ConversationRestarted   = "restarted"   // replaced by a new /swince
+	ConversationInterrupted = "interrupted" // the bot shut down
+)
+
+var (
+	Interactions = NewHistogram(
+		"swincebot_interaction_duration_seconds",
+		"Time taken to handle slash commands and buttons",
+		DurationBuckets, "kind", "name",
+	)
+	Conversations = NewCounter(
+		"swincebot_conversations_total",
+		"Swince submissions held in DMs, by how they ended",
+		"outcome",
+	)
+	Queries = NewHistogram(
+		"swincebot_db_query_duration_seconds",
+		"Time taken by database queries",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		"query",
+	)
+	GatewayReconnects = NewCounter(
+		"swincebot_gateway_reconnects_total",
+		"Connections to the Discord gateway after the first one",
+	)
+	OutboxDepth = NewGauge(
+		"swincebot_outbox_depth",
+		"Messages waiting to be sent to Discord",
+		"kind",
+	)
+	OutboxMessages = NewCounter(
+		"swincebot_outbox_messages_total",
+		"Messages taken out of the outbound queue, by outcome",
+		"outcome",
+	)
+	GatewayDisconnects = NewCounter(
+		"swincebot_gateway_disconnects_total",
+		"Losses of the Discord gateway connection",
+	)
+)
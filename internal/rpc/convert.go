@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/rpc/swincepb"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var verifications = map[string]swincepb.Verification{
+	database.VerificationPending:  swincepb.Verification_VERIFICATION_PENDING,
+	database.VerificationApproved: swincepb.Verification_VERIFICATION_APPROVED,
+	database.VerificationDisputed: swincepb.Verification_VERIFICATION_DISPUTED,
+	database.VerificationRejected: swincepb.Verification_VERIFICATION_REJECTED,
+}
+
+func toStats(b ruleset.Breakdown, rank int) *swincepb.Stats {
+	return &swincepb.Stats{
+		Rank:         uint32(rank),
+		Swinces:      uint32(b.Swinces),
+		Nominations:  uint32(b.Nominations),
+		Fulfillments: uint32(b.Fulfillments),
+		Total:        int64(b.Total),
+	}
+}
+
+// userStats returns a user's rank and breakdown within the given scores
+func userStats(scores ruleset.Scores, userID uint64) *swincepb.Stats {
+	for _, entry := range scores.Leaderboard(0) {
+		if entry.User.ID == userID {
+			return toStats(scores[userID], entry.Rank)
+		}
+	}
+	return toStats(scores[userID], 0)
+}
+
+func toEvent(e database.Event, swinces []database.Swince) *swincepb.Event {
+	event := &swincepb.Event{
+		EventId:      e.EventID,
+		GuildId:      e.GuildID,
+		Time:         timestamppb.New(e.Time),
+		Verification: verifications[e.Verification],
+	}
+	if e.Proof.Valid {
+		proof := uint64(e.Proof.Int64)
+		event.ProofMessageId = &proof
+	}
+	for _, s := range swinces {
+		event.Swinces = append(event.Swinces, &swincepb.Swince{
+			SwinceId:      s.SwinceID,
+			ParticipantId: s.ParticipantID,
+			NomineeId:     s.NomineeID,
+			FulfillmentId: s.FulfillmentID.String,
+		})
+	}
+	return event
+}
@@ -0,0 +1,75 @@
+// Package rpc implements the gRPC SwinceService on top of the bot and its database
+package rpc
+
+import (
+	"context"
+
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
+	"github.com/ChausseBenjamin/swincebot/internal/bot"
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/rpc/swincepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Bot is the part of the Discord bot the service relies on
+type Bot interface {
+	Submit(ctx context.Context, sub bot.Submission) (string, error)
+	IsMember(ctx context.Context, guildID, userID uint64) (bool, error)
+	Subscribe() (<-chan string, func())
+}
+
+// Service implements swincepb.SwinceServiceServer
+type Service struct {
+	swincepb.UnimplementedSwinceServiceServer
+
+	db  *database.ProtoDB
+	bot Bot
+}
+
+// scopes lists the token scope required by each method. Reflection stays
+// public so tools like grpcurl can discover the service before authenticating.
+var scopes = map[string]auth.Scope{
+	swincepb.SwinceService_ListGuilds_FullMethodName:                       auth.ScopeRead,
+	swincepb.SwinceService_GetLeaderboard_FullMethodName:                   auth.ScopeRead,
+	swincepb.SwinceService_GetUserStats_FullMethodName:                     auth.ScopeRead,
+	swincepb.SwinceService_ListSeasons_FullMethodName:                      auth.ScopeRead,
+	swincepb.SwinceService_ListEvents_FullMethodName:                       auth.ScopeRead,
+	swincepb.SwinceService_GetEvent_FullMethodName:                         auth.ScopeRead,
+	swincepb.SwinceService_StreamEvents_FullMethodName:                     auth.ScopeRead,
+	swincepb.SwinceService_SubmitSwince_FullMethodName:                     auth.ScopeSubmit,
+	reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:      auth.Public,
+	reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: auth.Public,
+}
+
+// NewServer returns a gRPC server exposing the SwinceService (and the
+// reflection service so tools like grpcurl can discover it). Every call goes
+// through the guard.
+func NewServer(db *database.ProtoDB, b Bot, guard *auth.Guard) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(guard.UnaryInterceptor(scopes)),
+		grpc.StreamInterceptor(guard.StreamInterceptor(scopes)),
+	)
+	swincepb.RegisterSwinceServiceServer(srv, &Service{
+		db:  db,
+		bot: b,
+	})
+	reflection.Register(srv)
+	return srv
+}
+
+// page applies the server's defaults and limits to a requested page
+func page(p *swincepb.Page) (limit, offset int) {
+	limit = int(p.GetLimit())
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	return min(limit, maxPageSize), int(p.GetOffset())
+}
@@ -0,0 +1,331 @@
+package rpc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/auth"
+	"github.com/ChausseBenjamin/swincebot/internal/bot"
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+	"github.com/ChausseBenjamin/swincebot/internal/rpc/swincepb"
+	"github.com/ChausseBenjamin/swincebot/internal/ruleset"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// internalError logs err and hides its details from the client
+func internalError(ctx context.Context, msg string, err error) error {
+	slog.ErrorContext(ctx, msg, logging.ErrKey, err)
+	return status.Error(codes.Internal, msg)
+}
+
+// guild checks that the requested guild is configured
+func (s *Service) guild(ctx context.Context, guildID uint64) error {
+	if guildID == 0 {
+		return status.Error(codes.InvalidArgument, "guild_id is required")
+	}
+	_, err := s.db.GetGuild(ctx, guildID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return status.Errorf(codes.NotFound, "guild %d isn't configured", guildID)
+	} else if err != nil {
+		return internalError(ctx, "Unable to get guild", err)
+	}
+	return nil
+}
+
+// season resolves an optional season index, defaulting to the guild's current season
+func (s *Service) season(ctx context.Context, guildID uint64, requested *uint32) (int, error) {
+	current, err := ruleset.CurrentSeason(ctx, s.db, guildID, time.Now())
+	if err != nil {
+		return 0, internalError(ctx, "Unable to find the current season", err)
+	}
+	if requested == nil {
+		return current, nil
+	}
+	if int(*requested) > current {
+		return 0, status.Errorf(codes.InvalidArgument, "season %d hasn't started (current season is %d)", *requested, current)
+	}
+	return int(*requested), nil
+}
+
+func (s *Service) ListGuilds(ctx context.Context, _ *swincepb.ListGuildsRequest) (*swincepb.ListGuildsResponse, error) {
+	guilds, err := s.db.ListGuilds(ctx)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to list guilds", err)
+	}
+
+	resp := &swincepb.ListGuildsResponse{}
+	for _, g := range guilds {
+		resp.Guilds = append(resp.Guilds, &swincepb.Guild{
+			GuildId:   g.GuildID,
+			ChannelId: g.ChannelID,
+			Ruleset:   g.Ruleset,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) GetLeaderboard(ctx context.Context, req *swincepb.GetLeaderboardRequest) (*swincepb.GetLeaderboardResponse, error) {
+	now := time.Now()
+	resp := &swincepb.GetLeaderboardResponse{}
+
+	guildID := req.GetGuildId()
+	if err := s.guild(ctx, guildID); err != nil {
+		return nil, err
+	}
+
+	var (
+		scores ruleset.Scores
+		err    error
+	)
+	if req.GetAllTime() {
+		scores, err = ruleset.AllTimeScores(ctx, s.db, guildID, now)
+	} else {
+		var idx int
+		if idx, err = s.season(ctx, guildID, req.Season); err != nil {
+			return nil, err
+		}
+		season := uint32(idx)
+		resp.Season = &season
+		scores, err = ruleset.SeasonScores(ctx, s.db, guildID, idx, now)
+	}
+	if err != nil {
+		return nil, internalError(ctx, "Unable to compute scores", err)
+	}
+
+	leaderboard := scores.Leaderboard(0)
+	resp.Total = uint32(len(leaderboard))
+
+	limit, offset := page(req.GetPage())
+	start := min(offset, len(leaderboard))
+	end := min(start+limit, len(leaderboard))
+	for _, entry := range leaderboard[start:end] {
+		resp.Entries = append(resp.Entries, &swincepb.LeaderboardEntry{
+			UserId: entry.User.ID,
+			Stats:  toStats(scores[entry.User.ID], entry.Rank),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Service) GetUserStats(ctx context.Context, req *swincepb.GetUserStatsRequest) (*swincepb.GetUserStatsResponse, error) {
+	now := time.Now()
+
+	guildID := req.GetGuildId()
+	if err := s.guild(ctx, guildID); err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	idx, err := s.season(ctx, guildID, req.Season)
+	if err != nil {
+		return nil, err
+	}
+
+	seasonScores, err := ruleset.SeasonScores(ctx, s.db, guildID, idx, now)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to compute scores", err)
+	}
+	allTimeScores, err := ruleset.AllTimeScores(ctx, s.db, guildID, now)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to compute scores", err)
+	}
+
+	return &swincepb.GetUserStatsResponse{
+		UserId:  req.GetUserId(),
+		Season:  uint32(idx),
+		Current: userStats(seasonScores, req.GetUserId()),
+		AllTime: userStats(allTimeScores, req.GetUserId()),
+	}, nil
+}
+
+func (s *Service) ListSeasons(ctx context.Context, req *swincepb.ListSeasonsRequest) (*swincepb.ListSeasonsResponse, error) {
+	guildID := req.GetGuildId()
+	if err := s.guild(ctx, guildID); err != nil {
+		return nil, err
+	}
+	starts, err := s.db.ListGuildSeasons(ctx, guildID)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to list seasons", err)
+	}
+	current, err := s.season(ctx, guildID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Season N starts at the (N-1)th start time and ends at the Nth one
+	resp := &swincepb.ListSeasonsResponse{}
+	for idx := 0; idx <= current; idx++ {
+		rs, err := ruleset.ForSeason(ctx, s.db, guildID, idx)
+		if err != nil {
+			return nil, internalError(ctx, "Unable to find the season's ruleset", err)
+		}
+		season := &swincepb.Season{
+			Index:   uint32(idx),
+			Current: idx == current,
+			Ruleset: rs.String(),
+		}
+		if idx > 0 {
+			season.Start = timestamppb.New(starts[idx-1].StartTime)
+		}
+		if idx < len(starts) {
+			season.End = timestamppb.New(starts[idx].StartTime)
+		}
+		resp.Seasons = append(resp.Seasons, season)
+	}
+	return resp, nil
+}
+
+func (s *Service) ListEvents(ctx context.Context, req *swincepb.ListEventsRequest) (*swincepb.ListEventsResponse, error) {
+	guildID := req.GetGuildId()
+	if err := s.guild(ctx, guildID); err != nil {
+		return nil, err
+	}
+	limit, offset := page(req.GetPage())
+
+	total, err := s.db.CountEvents(ctx, guildID)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to count events", err)
+	}
+	rows, err := s.db.ListEventsPage(ctx, database.ListEventsPageParams{
+		GuildID: guildID,
+		Limit:   int64(limit),
+		Offset:  int64(offset),
+	})
+	if err != nil {
+		return nil, internalError(ctx, "Unable to list events", err)
+	}
+
+	resp := &swincepb.ListEventsResponse{Total: uint32(total)}
+	for _, row := range rows {
+		swinces, err := s.db.ListEventSwinces(ctx, row.EventID)
+		if err != nil {
+			return nil, internalError(ctx, "Unable to list swinces", err)
+		}
+		resp.Events = append(resp.Events, toEvent(row, swinces))
+	}
+	return resp, nil
+}
+
+func (s *Service) GetEvent(ctx context.Context, req *swincepb.GetEventRequest) (*swincepb.Event, error) {
+	id, err := util.ParseUUID(ctx, util.ParseUUIDParams{
+		Str:         req.GetEventId(),
+		Subject:     "event_id",
+		Implication: codes.InvalidArgument,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guild(ctx, req.GetGuildId()); err != nil {
+		return nil, err
+	}
+
+	event, err := s.loadEvent(ctx, id.String())
+	if err != nil {
+		return nil, err
+	}
+	// Events of other guilds are none of the caller's business
+	if event.GetGuildId() != req.GetGuildId() {
+		return nil, status.Errorf(codes.NotFound, "event %s doesn't exist", id)
+	}
+	return event, nil
+}
+
+func (s *Service) SubmitSwince(ctx context.Context, req *swincepb.SubmitSwinceRequest) (*swincepb.Event, error) {
+	// Tokens only allow submitting on behalf of their owner
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, status.Error(codes.Unauthenticated, auth.ErrMissingToken.Error())
+	}
+	if err := s.guild(ctx, req.GetGuildId()); err != nil {
+		return nil, err
+	}
+	submitter := req.GetSubmitterId()
+	if submitter == 0 {
+		submitter = claims.UserID()
+	} else if submitter != claims.UserID() {
+		return nil, status.Error(codes.PermissionDenied, "swinces can only be submitted on behalf of the token's owner")
+	}
+	member, err := s.bot.IsMember(ctx, req.GetGuildId(), submitter)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to check guild membership", err)
+	}
+	if !member {
+		return nil, status.Errorf(codes.PermissionDenied, "the token's owner isn't a member of guild %d", req.GetGuildId())
+	}
+
+	sub := bot.Submission{
+		GuildID:     req.GetGuildId(),
+		SubmitterID: submitter,
+		Nominees:    make(map[uint64]*uint64, len(req.GetParticipants())),
+		ProofURL:    req.GetProofUrl(),
+	}
+	for _, p := range req.GetParticipants() {
+		sub.Participants = append(sub.Participants, p.GetUserId())
+		sub.Nominees[p.GetUserId()] = p.NomineeId
+	}
+
+	eventID, err := s.bot.Submit(ctx, sub)
+	if errors.Is(err, bot.ErrInvalidSubmission) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, internalError(ctx, "Unable to submit swince", err)
+	}
+
+	slog.InfoContext(ctx, "Swince submitted through gRPC", "event_id", eventID, "user_id", sub.SubmitterID, "guild_id", sub.GuildID)
+	return s.loadEvent(ctx, eventID)
+}
+
+func (s *Service) StreamEvents(req *swincepb.StreamEventsRequest, stream swincepb.SwinceService_StreamEventsServer) error {
+	ctx := stream.Context()
+
+	guildID := req.GetGuildId()
+	if guildID != 0 {
+		if err := s.guild(ctx, guildID); err != nil {
+			return err
+		}
+	}
+
+	events, unsubscribe := s.bot.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case eventID := <-events:
+			event, err := s.loadEvent(ctx, eventID)
+			if err != nil {
+				return err
+			}
+			if guildID != 0 && event.GetGuildId() != guildID {
+				continue
+			}
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) loadEvent(ctx context.Context, eventID string) (*swincepb.Event, error) {
+	event, err := s.db.GetEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "event %s doesn't exist", eventID)
+	} else if err != nil {
+		return nil, internalError(ctx, "Unable to get event", err)
+	}
+
+	swinces, err := s.db.ListEventSwinces(ctx, eventID)
+	if err != nil {
+		return nil, internalError(ctx, "Unable to list swinces", err)
+	}
+	return toEvent(event, swinces), nil
+}
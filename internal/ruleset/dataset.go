@@ -0,0 +1,121 @@
+package ruleset
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+// Swince is a single participant's swince within an event
+type Swince struct {
+	ID            string
+	ParticipantID uint64
+	NomineeID     *uint64 // nil when swincing for no-one
+	FulfillmentID string  // swince which answered this nomination ("" when still open)
+	Fulfills      string  // nomination (swince ID) this swince answered ("" if none)
+}
+
+// Event is a submitted video and the swinces performed in it
+type Event struct {
+	ID           string
+	Time         time.Time
+	Verification string
+	Swinces      []Swince
+}
+
+// Dataset holds everything a ruleset needs to score a season
+type Dataset struct {
+	Start  time.Time
+	End    time.Time
+	Events []Event // sorted by time
+}
+
+// LoadSeason fetches the dataset of a season from the database.
+// Season 0 covers everything before the first season start, season N ends
+// when season N+1 starts (or now if it's the current season).
+func LoadSeason(ctx context.Context, db *database.ProtoDB, guildID uint64, seasonIndex int, now time.Time) (Dataset, error) {
+	start, end, err := seasonRange(ctx, db, guildID, seasonIndex, now)
+	if err != nil {
+		return Dataset{}, err
+	}
+	return LoadRange(ctx, db, guildID, start, end)
+}
+
+// LoadRange fetches every event (and its swinces) a guild submitted in [start, end)
+func LoadRange(ctx context.Context, db *database.ProtoDB, guildID uint64, start, end time.Time) (Dataset, error) {
+	rows, err := db.GetSwincesBetween(ctx, database.GetSwincesBetweenParams{
+		GuildID: guildID,
+		Time:    start,
+		Time_2:  end,
+	})
+	if err != nil {
+		return Dataset{}, fmt.Errorf("getting swinces: %w", err)
+	}
+
+	ds := Dataset{Start: start, End: end}
+	index := make(map[string]int) // event ID -> position in ds.Events
+	seen := make(map[string]bool) // swince IDs already added
+	for _, row := range rows {
+		if seen[row.SwinceID] {
+			continue
+		}
+		seen[row.SwinceID] = true
+
+		pos, exists := index[row.EventID]
+		if !exists {
+			pos = len(ds.Events)
+			index[row.EventID] = pos
+			ds.Events = append(ds.Events, Event{
+				ID:           row.EventID,
+				Time:         row.Time,
+				Verification: row.Verification,
+			})
+		}
+
+		ds.Events[pos].Swinces = append(ds.Events[pos].Swinces, Swince{
+			ID:            row.SwinceID,
+			ParticipantID: row.ParticipantID,
+			NomineeID:     row.NomineeID,
+			FulfillmentID: row.FulfillmentID.String,
+			Fulfills:      row.Fulfills.String,
+		})
+	}
+
+	return ds, nil
+}
+
+// seasonRange returns the start and end time of a season
+func seasonRange(ctx context.Context, db *database.ProtoDB, guildID uint64, seasonIndex int, now time.Time) (time.Time, time.Time, error) {
+	if seasonIndex < 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid season index %d", seasonIndex)
+	}
+
+	var start time.Time
+	if seasonIndex > 0 {
+		var err error
+		start, err = db.GetSeasonStart(ctx, database.GetSeasonStartParams{
+			GuildID: guildID,
+			Offset:  int64(seasonIndex - 1),
+		})
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("getting season start: %w", err)
+		}
+	}
+
+	end, err := db.GetSeasonStart(ctx, database.GetSeasonStartParams{
+		GuildID: guildID,
+		Offset:  int64(seasonIndex),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Current season: it ends now
+		return start, now, nil
+	} else if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("getting season end: %w", err)
+	}
+
+	return start, end, nil
+}
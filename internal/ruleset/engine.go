@@ -0,0 +1,112 @@
+package ruleset
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/discord"
+)
+
+// Weights are the points awarded by a points-based ruleset
+type Weights struct {
+	Swince      int `json:"swince"`      // performing a swince
+	Nomination  int `json:"nomination"`  // to the nominator, once the nomination is fulfilled
+	Fulfillment int `json:"fulfillment"` // to the nominee, for fulfilling a nomination
+}
+
+// Breakdown details how a user's score was obtained
+type Breakdown struct {
+	Swinces      int
+	Nominations  int
+	Fulfillments int
+	Total        int
+}
+
+// Scores maps Discord user IDs to their score breakdown
+type Scores map[uint64]Breakdown
+
+// Engine is the pure scoring logic behind points-based rulesets.
+// It never touches the database: give it a Dataset and the current time
+// and it always returns the same scores.
+type Engine struct {
+	Weights Weights
+	// Pending swinces only count once this long passed without disputes
+	DisputeWindow time.Duration
+}
+
+// Counts reports whether an event counts toward scores at time now
+func (e Engine) Counts(ev Event, now time.Time) bool {
+	switch ev.Verification {
+	case database.VerificationApproved:
+		return true
+	case database.VerificationPending:
+		return !ev.Time.After(now.Add(-e.DisputeWindow))
+	default:
+		return false
+	}
+}
+
+// Score computes the breakdown of every user appearing in the dataset
+func (e Engine) Score(ds Dataset, now time.Time) Scores {
+	// Nominations are credited once the swince answering them counts, and
+	// answers once the nomination counts: a rejected swince mustn't earn
+	// points to anyone. Those from another season are taken as they are.
+	counting := make(map[string]bool)
+	for _, ev := range ds.Events {
+		counts := e.Counts(ev, now)
+		for _, s := range ev.Swinces {
+			counting[s.ID] = counts
+		}
+	}
+
+	scores := make(Scores)
+	for _, ev := range ds.Events {
+		if !e.Counts(ev, now) {
+			continue
+		}
+		for _, s := range ev.Swinces {
+			b := scores[s.ParticipantID]
+			b.Swinces++
+			if counts, known := counting[s.FulfillmentID]; s.NomineeID != nil && s.FulfillmentID != "" && (counts || !known) {
+				b.Nominations++
+			}
+			if counts, known := counting[s.Fulfills]; s.Fulfills != "" && (counts || !known) {
+				b.Fulfillments++
+			}
+			b.Total = b.Swinces*e.Weights.Swince +
+				b.Nominations*e.Weights.Nomination +
+				b.Fulfillments*e.Weights.Fulfillment
+			scores[s.ParticipantID] = b
+		}
+	}
+	return scores
+}
+
+// Leaderboard ranks users by total score (descending). Ties are broken by
+// user ID so the ranking is stable. A count of 0 or less keeps everyone.
+func (s Scores) Leaderboard(count int) Leaderboard {
+	leaderboard := make(Leaderboard, 0, len(s))
+	for userID, b := range s {
+		leaderboard = append(leaderboard, LeaderboardEntry{
+			User:  discord.User{ID: userID},
+			Score: b.Total,
+		})
+	}
+
+	slices.SortFunc(leaderboard, func(a, b LeaderboardEntry) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.User.ID, b.User.ID)
+	})
+
+	if count > 0 && count < len(leaderboard) {
+		leaderboard = leaderboard[:count]
+	}
+	for i := range leaderboard {
+		leaderboard[i].Rank = i + 1
+	}
+	return leaderboard
+}
@@ -0,0 +1,220 @@
+package ruleset
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+var (
+	testNow    = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	testEngine = Engine{
+		Weights:       Weights{Swince: 1, Nomination: 3, Fulfillment: 2},
+		DisputeWindow: 24 * time.Hour,
+	}
+)
+
+func nominee(id uint64) *uint64 {
+	return &id
+}
+
+func TestEngineCounts(t *testing.T) {
+	tests := []struct {
+		name         string
+		verification string
+		age          time.Duration
+		want         bool
+	}{
+		{"approved", database.VerificationApproved, time.Minute, true},
+		{"pending within the dispute window", database.VerificationPending, time.Hour, false},
+		{"pending at the end of the dispute window", database.VerificationPending, 24 * time.Hour, true},
+		{"pending after the dispute window", database.VerificationPending, 48 * time.Hour, true},
+		{"disputed", database.VerificationDisputed, 48 * time.Hour, false},
+		{"rejected", database.VerificationRejected, 48 * time.Hour, false},
+		{"unknown verification", "", 48 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := Event{Time: testNow.Add(-tt.age), Verification: tt.verification}
+			if got := testEngine.Counts(ev, testNow); got != tt.want {
+				t.Errorf("Counts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineScore(t *testing.T) {
+	old := testNow.Add(-48 * time.Hour)
+	recent := testNow.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   Scores
+	}{
+		{
+			name: "empty dataset",
+			want: Scores{},
+		},
+		{
+			name: "swinces",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1},
+					{ID: "s2", ParticipantID: 2},
+				}},
+				{ID: "e2", Time: old, Verification: database.VerificationPending, Swinces: []Swince{
+					{ID: "s3", ParticipantID: 1},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 2, Total: 2},
+				2: {Swinces: 1, Total: 1},
+			},
+		},
+		{
+			name: "open nomination",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2)},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 1, Total: 1},
+			},
+		},
+		{
+			name: "fulfilled nomination",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2), FulfillmentID: "s2"},
+				}},
+				{ID: "e2", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s2", ParticipantID: 2, Fulfills: "s1"},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 1, Nominations: 1, Total: 4},
+				2: {Swinces: 1, Fulfillments: 1, Total: 3},
+			},
+		},
+		{
+			name: "nomination fulfilled by a swince within the dispute window",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2), FulfillmentID: "s2"},
+				}},
+				{ID: "e2", Time: recent, Verification: database.VerificationPending, Swinces: []Swince{
+					{ID: "s2", ParticipantID: 2, Fulfills: "s1"},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 1, Total: 1},
+			},
+		},
+		{
+			name: "nomination fulfilled by a disputed swince",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2), FulfillmentID: "s2"},
+				}},
+				{ID: "e2", Time: old, Verification: database.VerificationDisputed, Swinces: []Swince{
+					{ID: "s2", ParticipantID: 2, Fulfills: "s1"},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 1, Total: 1},
+			},
+		},
+		{
+			name: "nomination fulfilled in another season",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2), FulfillmentID: "elsewhere"},
+				}},
+			},
+			want: Scores{
+				1: {Swinces: 1, Nominations: 1, Total: 4},
+			},
+		},
+		{
+			// Rejected after being answered: the answer still counts as a
+			// swince, but a rejected swince earns points to no-one
+			name: "nomination rejected once fulfilled",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationRejected, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 1, NomineeID: nominee(2), FulfillmentID: "s2"},
+				}},
+				{ID: "e2", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s2", ParticipantID: 2, Fulfills: "s1"},
+				}},
+			},
+			want: Scores{
+				2: {Swinces: 1, Total: 1},
+			},
+		},
+		{
+			name: "answer to a nomination from another season",
+			events: []Event{
+				{ID: "e1", Time: old, Verification: database.VerificationApproved, Swinces: []Swince{
+					{ID: "s1", ParticipantID: 2, Fulfills: "elsewhere"},
+				}},
+			},
+			want: Scores{
+				2: {Swinces: 1, Fulfillments: 1, Total: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testEngine.Score(Dataset{Events: tt.events}, testNow)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoresLeaderboard(t *testing.T) {
+	scores := Scores{
+		4: {Total: 2},
+		1: {Total: 5},
+		3: {Total: 5},
+		2: {Total: 7},
+	}
+
+	tests := []struct {
+		name  string
+		count int
+		want  []uint64 // user IDs, by rank
+	}{
+		{"everyone", 0, []uint64{2, 1, 3, 4}},
+		{"negative count keeps everyone", -1, []uint64{2, 1, 3, 4}},
+		{"top users", 2, []uint64{2, 1}},
+		{"count above the number of users", 10, []uint64{2, 1, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := scores.Leaderboard(tt.count)
+			var got []uint64
+			for i, entry := range board {
+				if entry.Rank != i+1 {
+					t.Errorf("entry %d has rank %d", i, entry.Rank)
+				}
+				if entry.Score != scores[entry.User.ID].Total {
+					t.Errorf("user %d has score %d, want %d", entry.User.ID, entry.Score, scores[entry.User.ID].Total)
+				}
+				got = append(got, entry.User.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Leaderboard(%d) ranked %v, want %v", tt.count, got, tt.want)
+			}
+		})
+	}
+}
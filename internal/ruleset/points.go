@@ -0,0 +1,30 @@
+package ruleset
+
+import (
+	"time"
+)
+
+// points is a ruleset where each kind of action is worth a fixed amount of
+// points. Scoring is delegated to the Engine.
+type points struct {
+	description string
+	engine      Engine
+}
+
+func newPoints(disputeWindow time.Duration, w Weights, description string) *points {
+	return &points{
+		description: description,
+		engine: Engine{
+			Weights:       w,
+			DisputeWindow: disputeWindow,
+		},
+	}
+}
+
+func (rs *points) String() string {
+	return rs.description
+}
+
+func (rs *points) Scores(ds Dataset, now time.Time) Scores {
+	return rs.engine.Score(ds, now)
+}
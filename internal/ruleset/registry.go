@@ -0,0 +1,78 @@
+package ruleset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+// Default is the ruleset new guilds play under
+const Default = "v0"
+
+// builtins maps ruleset names to their constructor
+var builtins = map[string]func(disputeWindow time.Duration) Ruleset{
+	"v0": NewV0,
+}
+
+// Builtins lists the names of the builtin rulesets
+func Builtins() []string {
+	return slices.Sorted(maps.Keys(builtins))
+}
+
+// pointsFile is the format of a candidate ruleset stored on disk, ex:
+//
+//	{"description": "Nominations matter more", "weights": {"swince": 1, "nomination": 3, "fulfillment": 2}}
+type pointsFile struct {
+	Description string  `json:"description"`
+	Weights     Weights `json:"weights"`
+}
+
+// Load returns the builtin ruleset with the given name or, failing that,
+// reads a points-based ruleset from the JSON file at that path.
+func Load(nameOrPath string, disputeWindow time.Duration) (Ruleset, error) {
+	if builtin, exists := builtins[nameOrPath]; exists {
+		return builtin(disputeWindow), nil
+	}
+
+	buf, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a known ruleset nor a readable file: %w", nameOrPath, err)
+	}
+
+	var f pointsFile
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, fmt.Errorf("parsing ruleset file %s: %w", nameOrPath, err)
+	}
+	if f.Description == "" {
+		f.Description = fmt.Sprintf("Custom ruleset (%s)", nameOrPath)
+	}
+
+	return newPoints(disputeWindow, f.Weights, f.Description), nil
+}
+
+// ForSeason returns the ruleset a guild scores a season with: the one recorded
+// when the season started, or the guild's own ruleset for season 0.
+func ForSeason(ctx context.Context, db *database.ProtoDB, guildID uint64, seasonIndex int) (Ruleset, error) {
+	if seasonIndex == 0 {
+		guild, err := db.GetGuild(ctx, guildID)
+		if err != nil {
+			return nil, fmt.Errorf("getting guild: %w", err)
+		}
+		return Named(guild.Ruleset)
+	}
+
+	seasons, err := db.ListGuildSeasons(ctx, guildID)
+	if err != nil {
+		return nil, fmt.Errorf("listing seasons: %w", err)
+	}
+	if seasonIndex < 0 || seasonIndex > len(seasons) {
+		return nil, fmt.Errorf("invalid season index %d", seasonIndex)
+	}
+	return Named(seasons[seasonIndex-1].Ruleset)
+}
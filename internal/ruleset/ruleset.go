@@ -1,82 +1,53 @@
 package ruleset
 
 import (
-	"context"
 	"fmt"
+	"sync"
 	"time"
-
-	"github.com/ChausseBenjamin/swincebot/internal/database"
-	"github.com/ChausseBenjamin/swincebot/internal/discord"
 )
 
-const seasonNotSet = -1
-
-// Ruleset defines the interface for scoring and leaderboard generation
-// Each ruleset implementation represents a different season's scoring rules
+// Ruleset defines how a season gets scored. Rulesets are shared by every
+// guild and season using them, so they never touch the database themselves.
 type Ruleset interface {
 	// String returns a human-readable explanation of the ruleset
 	String() string
 
-	// Calc calculates the score for a specific user under this ruleset
-	Score(ctx context.Context, u discord.User) (int, error)
-
-	// ScoreStr returns a "fancier" score string for a given ruleset
-	// ex: "Total: xPts, Nominations: 4 (yPts), Responses 3 (zPtz)"
-	ScoreStr(ctx context.Context, u discord.User) (string, error)
-
-	// Leaderboard returns the top 'count' users with their scores and rankings
-	Leaderboard(ctx context.Context, count int) (Leaderboard, error)
-
-	// setSeason sets the season index for this ruleset instance (called at startup)
-	setSeason(seasonIndex int)
+	// Scores computes every user's score from a season's dataset without
+	// touching the database (useful to replay history under another ruleset)
+	Scores(ds Dataset, now time.Time) Scores
 }
 
-// rulesets is the global slice - index matches season number
-// index 0 being the 0th season before the first season timestamp
-var rulesets []Ruleset
-
-// InitializeRulesets initializes all rulesets with their season indices
-func InitializeRulesets(db *database.ProtoDB) {
-	rulesets = []Ruleset{
-		&v0{db: db, seasonIndex: seasonNotSet},
-		// Future seasons can be pre-allocated here
-	}
+var (
+	mu            sync.Mutex
+	disputeWindow time.Duration
+	loaded        map[string]Ruleset // keyed by name or path, nil until initialized
+)
 
-	// Set season index for each ruleset
-	for i, ruleset := range rulesets {
-		ruleset.setSeason(i)
-	}
+// InitializeRulesets must be called at startup. Pending swinces only start
+// counting once disputeWindow elapsed without disputes.
+func InitializeRulesets(window time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	disputeWindow = window
+	loaded = make(map[string]Ruleset)
 }
 
-// Get returns the appropriate ruleset for a given timestamp
-// Rulesets should be initialized at startup, so no context is needed
-func Get(t time.Time) (Ruleset, error) {
-	if len(rulesets) == 0 {
+// Named returns the builtin ruleset with the given name or the one described
+// by the file at that path. Each ruleset is only loaded once.
+func Named(nameOrPath string) (Ruleset, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if loaded == nil {
 		return nil, fmt.Errorf("rulesets not initialized")
 	}
-
-	// Default to the first (v0) ruleset for now
-	// TODO: Implement proper season detection based on timestamp and database
-	return rulesets[0], nil
-}
-
-// GetWithDB returns the appropriate ruleset for a given timestamp using database queries
-func GetWithDB(ctx context.Context, t time.Time, db *database.ProtoDB) (Ruleset, error) {
-	if len(rulesets) == 0 {
-		return nil, fmt.Errorf("rulesets not initialized")
+	if rs, exists := loaded[nameOrPath]; exists {
+		return rs, nil
 	}
 
-	// Get the season ID for the given timestamp
-	seasonID, err := db.GetSeasonID(ctx, t)
+	rs, err := Load(nameOrPath, disputeWindow)
 	if err != nil {
-		// If no season found, default to v0
-		return rulesets[0], nil
-	}
-
-	// Ensure we don't go out of bounds
-	if int(seasonID) >= len(rulesets) {
-		return rulesets[len(rulesets)-1], nil
+		return nil, err
 	}
-
-	return rulesets[seasonID], nil
+	loaded[nameOrPath] = rs
+	return rs, nil
 }
@@ -0,0 +1,73 @@
+package ruleset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+var logger = logging.Subsystem("ruleset")
+
+// CurrentSeason returns the index of the guild's season in progress at time now
+func CurrentSeason(ctx context.Context, db *database.ProtoDB, guildID uint64, now time.Time) (int, error) {
+	season, err := db.GetSeasonID(ctx, database.GetSeasonIDParams{
+		GuildID:   guildID,
+		StartTime: now,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("getting current season: %w", err)
+	}
+	return int(season), nil
+}
+
+// SeasonScores scores any season with the ruleset that was in effect during it
+func SeasonScores(ctx context.Context, db *database.ProtoDB, guildID uint64, season int, now time.Time) (Scores, error) {
+	rs, err := ForSeason(ctx, db, guildID, season)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := LoadSeason(ctx, db, guildID, season, now)
+	if err != nil {
+		return nil, fmt.Errorf("loading season %d: %w", season, err)
+	}
+
+	start := time.Now()
+	scores := rs.Scores(ds, now)
+	logger.DebugContext(ctx, "Scored season",
+		"guild_id", guildID,
+		"season", season,
+		"events", len(ds.Events),
+		"users", len(scores),
+		"took", time.Since(start),
+	)
+	return scores, nil
+}
+
+// AllTimeScores adds up every season's scores, each computed with its own ruleset
+func AllTimeScores(ctx context.Context, db *database.ProtoDB, guildID uint64, now time.Time) (Scores, error) {
+	current, err := CurrentSeason(ctx, db, guildID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(Scores)
+	for season := 0; season <= current; season++ {
+		scores, err := SeasonScores(ctx, db, guildID, season, now)
+		if err != nil {
+			return nil, err
+		}
+		for userID, b := range scores {
+			t := totals[userID]
+			t.Swinces += b.Swinces
+			t.Nominations += b.Nominations
+			t.Fulfillments += b.Fulfillments
+			t.Total += b.Total
+			totals[userID] = t
+		}
+	}
+	return totals, nil
+}
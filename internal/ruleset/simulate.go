@@ -0,0 +1,48 @@
+package ruleset
+
+// Comparison pairs a user's actual standing with a simulated one
+type Comparison struct {
+	UserID         uint64 `json:"user_id"`
+	ActualRank     int    `json:"actual_rank"`
+	ActualScore    int    `json:"actual_score"`
+	SimulatedRank  int    `json:"simulated_rank"`
+	SimulatedScore int    `json:"simulated_score"`
+	RankDelta      int    `json:"rank_delta"` // positive when the user climbs under the simulated ruleset
+}
+
+// Compare lines up two leaderboards of the same season, ordered by simulated rank.
+// Users missing from one of them get a rank of 0.
+func Compare(actual, simulated Leaderboard) []Comparison {
+	byUser := make(map[uint64]LeaderboardEntry, len(actual))
+	for _, entry := range actual {
+		byUser[entry.User.ID] = entry
+	}
+
+	comparisons := make([]Comparison, 0, len(simulated))
+	for _, entry := range simulated {
+		c := Comparison{
+			UserID:         entry.User.ID,
+			SimulatedRank:  entry.Rank,
+			SimulatedScore: entry.Score,
+		}
+		if before, exists := byUser[entry.User.ID]; exists {
+			c.ActualRank = before.Rank
+			c.ActualScore = before.Score
+			c.RankDelta = before.Rank - entry.Rank
+			delete(byUser, entry.User.ID)
+		}
+		comparisons = append(comparisons, c)
+	}
+
+	for _, entry := range actual {
+		if _, missing := byUser[entry.User.ID]; missing {
+			comparisons = append(comparisons, Comparison{
+				UserID:      entry.User.ID,
+				ActualRank:  entry.Rank,
+				ActualScore: entry.Score,
+			})
+		}
+	}
+
+	return comparisons
+}
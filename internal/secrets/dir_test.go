@@ -0,0 +1,32 @@
+package secrets_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets/vaulttest"
+)
+
+func TestDirVault(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "discord_bot_token"), []byte("token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := secrets.NewDirVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = vaulttest.TestVault(v, map[string]secrets.Secret{"discord_bot_token": "token"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirVaultMissingDir(t *testing.T) {
+	if _, err := secrets.NewDirVault(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("NewDirVault accepted a missing directory")
+	}
+}
@@ -0,0 +1,35 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvVault reads secrets from environment variables. Keys are upper-cased and
+// prefixed: with the prefix "SWINCEBOT_", discord_bot_token is read from
+// SWINCEBOT_DISCORD_BOT_TOKEN. Environment variables can't be persisted so
+// the vault is read-only.
+type EnvVault struct {
+	prefix string
+}
+
+func NewEnvVault(prefix string) *EnvVault {
+	return &EnvVault{prefix: prefix}
+}
+
+func (src *EnvVault) variable(key string) string {
+	return src.prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+}
+
+func (src *EnvVault) Get(key string) (Secret, error) {
+	val, exists := os.LookupEnv(src.variable(key))
+	if !exists {
+		return "", fmt.Errorf("%w: %s (looked for $%s)", ErrNotFound, key, src.variable(key))
+	}
+	return Secret(val), nil
+}
+
+func (src *EnvVault) Set(key string, val Secret) error {
+	return fmt.Errorf("%w: set $%s instead", ErrReadOnly, src.variable(key))
+}
@@ -0,0 +1,22 @@
+package secrets_test
+
+import (
+	"testing"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets/vaulttest"
+)
+
+func TestEnvVault(t *testing.T) {
+	t.Setenv("SWINCEBOT_TEST_DISCORD_BOT_TOKEN", "token")
+	t.Setenv("SWINCEBOT_TEST_JWT_KEY", "key")
+
+	v := secrets.NewEnvVault("SWINCEBOT_TEST_")
+	err := vaulttest.TestVault(v, map[string]secrets.Secret{
+		"discord_bot_token": "token",
+		"jwt-key":           "key",
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
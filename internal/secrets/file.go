@@ -0,0 +1,169 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// fileMagic starts every encrypted vault so other files are rejected early
+var fileMagic = []byte("swincevault1")
+
+const (
+	saltSize  = 16
+	nonceSize = 24
+
+	// scrypt parameters recommended for interactive logins (2017)
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted vault")
+
+// FileVault keeps every secret in a single file encrypted with NaCl's
+// secretbox. The key is derived from a passphrase with scrypt.
+//
+// Layout: magic | salt (16 bytes) | nonce (24 bytes) | sealed JSON object
+type FileVault struct {
+	path string
+	key  [32]byte
+	salt []byte
+
+	mu      sync.Mutex
+	secrets map[string]Secret
+}
+
+// NewFileVault unlocks the vault at path. The file gets created on the first
+// Set when it doesn't exist yet.
+func NewFileVault(path string, passphrase Secret) (*FileVault, error) {
+	if passphrase == "" {
+		return nil, errors.New("an encrypted vault needs a passphrase")
+	}
+
+	v := &FileVault{path: path, secrets: make(map[string]Secret)}
+
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		v.salt = make([]byte, saltSize)
+		if _, err := rand.Read(v.salt); err != nil {
+			return nil, fmt.Errorf("generating salt: %w", err)
+		}
+		return v, v.deriveKey(passphrase)
+	} else if err != nil {
+		return nil, fmt.Errorf("reading vault %s: %w", path, err)
+	}
+
+	header := len(fileMagic) + saltSize + nonceSize
+	if len(buf) < header+secretbox.Overhead || !bytes.HasPrefix(buf, fileMagic) {
+		return nil, fmt.Errorf("%s is not an encrypted vault", path)
+	}
+	v.salt = buf[len(fileMagic) : len(fileMagic)+saltSize]
+	if err := v.deriveKey(passphrase); err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], buf[len(fileMagic)+saltSize:header])
+	plain, ok := secretbox.Open(nil, buf[header:], &nonce, &v.key)
+	if !ok {
+		return nil, ErrBadPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("parsing vault %s: %w", path, err)
+	}
+	return v, nil
+}
+
+// NewFileVaultFromKeyFile unlocks the vault with the content of a key file
+func NewFileVaultFromKeyFile(path, keyFile string) (*FileVault, error) {
+	passphrase, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	return NewFileVault(path, Secret(bytes.TrimSpace(passphrase)))
+}
+
+func (src *FileVault) deriveKey(passphrase Secret) error {
+	key, err := scrypt.Key(passphrase.Bytes(), src.salt, scryptN, scryptR, scryptP, len(src.key))
+	if err != nil {
+		return fmt.Errorf("deriving vault key: %w", err)
+	}
+	copy(src.key[:], key)
+	return nil
+}
+
+func (src *FileVault) Get(key string) (Secret, error) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	val, exists := src.secrets[key]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return val, nil
+}
+
+// Set re-encrypts the whole vault (with a fresh nonce) and atomically
+// replaces the file
+func (src *FileVault) Set(key string, val Secret) error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	previous, existed := src.secrets[key]
+	src.secrets[key] = val
+	if err := src.save(); err != nil {
+		if existed {
+			src.secrets[key] = previous
+		} else {
+			delete(src.secrets, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (src *FileVault) save() error {
+	plain, err := json.Marshal(src.secrets)
+	if err != nil {
+		return fmt.Errorf("encoding vault: %w", err)
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+
+	buf := make([]byte, 0, len(fileMagic)+saltSize+nonceSize+len(plain)+secretbox.Overhead)
+	buf = append(buf, fileMagic...)
+	buf = append(buf, src.salt...)
+	buf = append(buf, nonce[:]...)
+	buf = secretbox.Seal(buf, plain, &nonce, &src.key)
+
+	tmp, err := os.CreateTemp(filepath.Dir(src.path), ".vault-*")
+	if err != nil {
+		return fmt.Errorf("unable to write vault: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close() //nolint:errcheck
+		return fmt.Errorf("unable to write vault: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write vault: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), src.path); err != nil {
+		return fmt.Errorf("unable to write vault: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package secrets_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets/vaulttest"
+)
+
+func TestFileVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault")
+
+	v, err := secrets.NewFileVault(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vaulttest.TestVault(v, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Secrets written above must survive unlocking the file again
+	reopened, err := secrets.NewFileVault(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vaulttest.TestVault(reopened, map[string]secrets.Secret{"vaulttest_key": "overwritten"}, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileVaultWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault")
+	v, err := secrets.NewFileVault(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Set("key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := secrets.NewFileVault(path, "wrong"); !errors.Is(err, secrets.ErrBadPassphrase) {
+		t.Fatalf("NewFileVault with the wrong passphrase returned %v, want ErrBadPassphrase", err)
+	}
+}
+
+func TestFileVaultFromKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("passphrase\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := secrets.NewFileVaultFromKeyFile(filepath.Join(dir, "vault"), keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vaulttest.TestVault(v, nil, false); err != nil {
+		t.Fatal(err)
+	}
+}
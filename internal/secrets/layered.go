@@ -0,0 +1,38 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LayeredVault looks secrets up in several vaults, in order. Secrets are
+// written to the first vault which isn't read-only.
+type LayeredVault struct {
+	layers []SecretVault
+}
+
+func NewLayeredVault(layers ...SecretVault) *LayeredVault {
+	return &LayeredVault{layers: layers}
+}
+
+func (src *LayeredVault) Get(key string) (Secret, error) {
+	for _, layer := range src.layers {
+		val, err := layer.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return val, err
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+func (src *LayeredVault) Set(key string, val Secret) error {
+	for _, layer := range src.layers {
+		err := layer.Set(key, val)
+		if errors.Is(err, ErrReadOnly) {
+			continue
+		}
+		return err
+	}
+	return fmt.Errorf("%w: no writable layer", ErrReadOnly)
+}
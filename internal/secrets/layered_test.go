@@ -0,0 +1,44 @@
+package secrets_test
+
+import (
+	"testing"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+	"github.com/ChausseBenjamin/swincebot/internal/secrets/vaulttest"
+)
+
+func TestLayeredVault(t *testing.T) {
+	t.Setenv("SWINCEBOT_TEST_SHADOWED", "env")
+	dir, err := secrets.NewDirVault(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Set("shadowed", "dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Set("fallback", "dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes skip the read-only environment and land in the directory
+	v := secrets.NewLayeredVault(secrets.NewEnvVault("SWINCEBOT_TEST_"), dir)
+	err = vaulttest.TestVault(v, map[string]secrets.Secret{
+		"shadowed": "env",
+		"fallback": "dir",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dir.Get("vaulttest_key"); err != nil || got != "overwritten" {
+		t.Fatalf("directory layer holds %q (%v), want %q", got, err, "overwritten")
+	}
+}
+
+func TestLayeredVaultReadOnly(t *testing.T) {
+	t.Setenv("SWINCEBOT_TEST_KEY", "value")
+
+	v := secrets.NewLayeredVault(secrets.NewEnvVault("SWINCEBOT_TEST_"))
+	if err := vaulttest.TestVault(v, map[string]secrets.Secret{"key": "value"}, true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,21 @@
 package secrets
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
 )
 
+var (
+	// ErrNotFound is returned by Get when the vault holds no such secret
+	ErrNotFound = errors.New("secret not found")
+	// ErrReadOnly is returned by Set on vaults which can't persist secrets
+	ErrReadOnly = errors.New("vault is read-only")
+)
+
 type Secret string
 
 type SecretVault interface {
@@ -32,7 +41,9 @@ func (s Secret) LogValue() slog.Value {
 
 func (src *DirVault) Get(key string) (Secret, error) {
 	buf, err := os.ReadFile(fmt.Sprintf("%s/%s", src.dir, key))
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, key)
+	} else if err != nil {
 		return "", err
 	}
 	return Secret(buf), nil
@@ -0,0 +1,71 @@
+// Package vaulttest checks that SecretVault implementations behave alike, in
+// the spirit of testing/fstest.TestFS
+package vaulttest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+)
+
+// missingKey is never expected to exist in a vault under test
+const missingKey = "vaulttest_missing_secret"
+
+// TestVault runs every check against v and reports all failures at once.
+// preset are secrets v is expected to already hold. Unless readOnly is set,
+// v must accept new secrets and overwrites. Checks are skipped accordingly
+// for read-only vaults, which must then reject writes with ErrReadOnly.
+func TestVault(v secrets.SecretVault, preset map[string]secrets.Secret, readOnly bool) error {
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := v.Get(missingKey); !errors.Is(err, secrets.ErrNotFound) {
+		fail("Get(%q) on a missing secret returned %v, want ErrNotFound", missingKey, err)
+	}
+
+	for key, want := range preset {
+		if got, err := v.Get(key); err != nil {
+			fail("Get(%q) returned %v", key, err)
+		} else if got != want {
+			fail("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	if readOnly {
+		if err := v.Set("vaulttest_key", "value"); !errors.Is(err, secrets.ErrReadOnly) {
+			fail("Set on a read-only vault returned %v, want ErrReadOnly", err)
+		}
+	} else {
+		for key, val := range map[string]secrets.Secret{
+			"vaulttest_key":       "value",
+			"vaulttest_multiline": "line 1\nline 2\n",
+			"vaulttest_binary":    secrets.Secret([]byte{0, 1, 2, 0xff}),
+			"vaulttest_empty":     "",
+		} {
+			if err := v.Set(key, val); err != nil {
+				fail("Set(%q) returned %v", key, err)
+				continue
+			}
+			if got, err := v.Get(key); err != nil {
+				fail("Get(%q) after Set returned %v", key, err)
+			} else if got != val {
+				fail("Get(%q) after Set = %q, want %q", key, got, val)
+			}
+		}
+
+		if err := v.Set("vaulttest_key", "overwritten"); err != nil {
+			fail("overwriting a secret returned %v", err)
+		} else if got, _ := v.Get("vaulttest_key"); got != "overwritten" {
+			fail("Get after overwrite = %q, want %q", got, "overwritten")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("vault conformance failed:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return nil
+}
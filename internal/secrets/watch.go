@@ -0,0 +1,134 @@
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+// Watcher is implemented by vaults which can notice their secrets changing
+// under them. Watch sends the key of every secret added, changed or removed
+// until ctx is done, at which point the channel is closed.
+type Watcher interface {
+	Watch(ctx context.Context, interval time.Duration) <-chan string
+}
+
+type fileState struct {
+	target  string // file the entry resolves to, through symlinks
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records the state of every file in the vault directory. Symlinks
+// are followed: mounted Kubernetes/Docker secrets link each key into a
+// "..data" directory, swapped on every update. Those "..*" bookkeeping
+// entries aren't secrets.
+func (src *DirVault) snapshot() (map[string]fileState, error) {
+	entries, err := os.ReadDir(src.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fileState, len(entries))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		path := filepath.Join(src.dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			continue
+		}
+		files[entry.Name()] = fileState{target: target, modTime: info.ModTime(), size: info.Size()}
+	}
+	return files, nil
+}
+
+// Watch polls the vault directory every interval. Polling (rather than inotify)
+// keeps working with the symlink swaps used by mounted Kubernetes/Docker secrets.
+func (src *DirVault) Watch(ctx context.Context, interval time.Duration) <-chan string {
+	changes := make(chan string)
+	go func() {
+		defer close(changes)
+
+		prev, err := src.snapshot()
+		if err != nil {
+			slog.WarnContext(ctx, "Unable to read secrets directory", logging.ErrKey, err, "dir", src.dir)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			curr, err := src.snapshot()
+			if err != nil {
+				slog.WarnContext(ctx, "Unable to read secrets directory", logging.ErrKey, err, "dir", src.dir)
+				continue
+			}
+
+			var changed []string
+			for name, state := range curr {
+				if old, ok := prev[name]; !ok || old != state {
+					changed = append(changed, name)
+				}
+			}
+			for name := range prev {
+				if _, ok := curr[name]; !ok {
+					changed = append(changed, name)
+				}
+			}
+			prev = curr
+
+			for _, name := range changed {
+				select {
+				case changes <- name:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes
+}
+
+// Watch merges the changes of every layer which can be watched
+func (src *LayeredVault) Watch(ctx context.Context, interval time.Duration) <-chan string {
+	changes := make(chan string)
+	var wg sync.WaitGroup
+	for _, layer := range src.layers {
+		w, ok := layer.(Watcher)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(layerChanges <-chan string) {
+			defer wg.Done()
+			for key := range layerChanges {
+				select {
+				case changes <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(w.Watch(ctx, interval))
+	}
+	go func() {
+		wg.Wait()
+		close(changes)
+	}()
+	return changes
+}
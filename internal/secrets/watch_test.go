@@ -0,0 +1,70 @@
+package secrets_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/secrets"
+)
+
+// mountSecrets lays out dir like a mounted Kubernetes secret: each key links
+// into "..data", itself a link to the current version's directory
+func mountSecrets(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	versionDir := filepath.Join(dir, version)
+	if err := os.Mkdir(versionDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(versionDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join("..data", name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The swap is atomic: a new link renamed over the old one
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(version, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirVaultWatchSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	mountSecrets(t, dir, "..v1", map[string]string{"discord_bot_token": "old"})
+
+	v, err := secrets.NewDirVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := v.Watch(ctx, 10*time.Millisecond)
+
+	// Let the watcher take its first snapshot
+	time.Sleep(50 * time.Millisecond)
+	mountSecrets(t, dir, "..v2", map[string]string{"discord_bot_token": "new"})
+
+	select {
+	case key := <-changes:
+		if key != "discord_bot_token" {
+			t.Fatalf("Watch reported %q, want discord_bot_token", key)
+		}
+	case <-ctx.Done():
+		t.Fatal("Watch didn't notice the rotated secret")
+	}
+	if got, err := v.Get("discord_bot_token"); err != nil || got != "new" {
+		t.Fatalf("Get after rotation = %q (%v), want %q", got, err, "new")
+	}
+}
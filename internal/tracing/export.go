@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/logging"
+)
+
+const (
+	queueSize     = 4096 // spans waiting to be exported, newer ones get dropped
+	batchSize     = 256
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter ships finished spans somewhere. Export is never called
+// concurrently and must not keep the slice it is given.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Close() error
+}
+
+var (
+	pipeline struct {
+		mu    sync.RWMutex
+		queue chan SpanData // nil when spans aren't exported
+		done  chan struct{}
+	}
+	dropped atomic.Int64
+)
+
+// Enable exports every span ending from now on through e, until Shutdown.
+// Spans are still created without an exporter: their IDs show up in logs.
+func Enable(e Exporter) {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	pipeline.queue = make(chan SpanData, queueSize)
+	pipeline.done = make(chan struct{})
+	go run(e, pipeline.queue, pipeline.done)
+}
+
+// Shutdown exports the spans still queued and closes the exporter
+func Shutdown(ctx context.Context) error {
+	pipeline.mu.Lock()
+	queue, done := pipeline.queue, pipeline.done
+	pipeline.queue = nil
+	pipeline.mu.Unlock()
+
+	if queue == nil {
+		return nil
+	}
+	close(queue)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func export(s *Span) {
+	pipeline.mu.RLock()
+	defer pipeline.mu.RUnlock()
+	if pipeline.queue == nil {
+		return
+	}
+	select {
+	case pipeline.queue <- s.data():
+	default:
+		dropped.Add(1)
+	}
+}
+
+// run batches queued spans until the queue gets closed
+func run(e Exporter, queue <-chan SpanData, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if n := dropped.Swap(0); n > 0 {
+			slog.Warn("Dropped spans, the exporter can't keep up", "spans", n)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		defer cancel()
+		if err := e.Export(ctx, batch); err != nil {
+			slog.Warn("Failed to export spans", logging.ErrKey, err, "spans", len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s, ok := <-queue:
+			if !ok {
+				flush()
+				if err := e.Close(); err != nil {
+					slog.Warn("Failed to close span exporter", logging.ErrKey, err)
+				}
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// serviceName identifies the bot among the services of a collector
+const serviceName = "swincebot"
+
+// FileExporter appends spans to a file, one JSON object per line, for
+// inspection without a collector (ex: with jq)
+type FileExporter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+type fileSpan struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       string         `json:"kind"`
+	Start      string         `json:"start"`
+	DurationMS float64        `json:"duration_ms"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+var kindNames = map[Kind]string{KindInternal: "internal", KindServer: "server", KindClient: "client"}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace file: %w", err)
+	}
+	return &FileExporter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (e *FileExporter) Export(_ context.Context, spans []SpanData) error {
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		line := fileSpan{
+			TraceID:    s.TraceID.String(),
+			SpanID:     s.SpanID.String(),
+			Name:       s.Name,
+			Kind:       kindNames[s.Kind],
+			Start:      s.Start.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+			DurationMS: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+		}
+		if !s.ParentID.IsZero() {
+			line.ParentID = s.ParentID.String()
+		}
+		if len(s.Attrs) > 0 {
+			line.Attributes = make(map[string]any, len(s.Attrs))
+			for _, a := range s.Attrs {
+				line.Attributes[a.Key] = a.Value.Resolve().Any()
+			}
+		}
+		if s.Err != nil {
+			line.Error = s.Err.Error()
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return e.w.Flush()
+}
+
+func (e *FileExporter) Close() error {
+	if err := e.w.Flush(); err != nil {
+		e.f.Close() //nolint:errcheck
+		return err
+	}
+	return e.f.Close()
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP over
+// HTTP with the JSON encoding
+type OTLPExporter struct {
+	endpoint string // ex: http://localhost:4318/v1/traces
+	client   *http.Client
+}
+
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, client: &http.Client{Timeout: exportTimeout}}
+}
+
+// OTLP/JSON payload (see opentelemetry-proto's trace.proto). IDs are hex
+// encoded and timestamps are nanoseconds written as strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0: unset, 2: error
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	scope := otlpScopeSpans{
+		Scope: otlpScope{Name: "github.com/ChausseBenjamin/swincebot"},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if !s.ParentID.IsZero() {
+			span.ParentSpanID = s.ParentID.String()
+		}
+		for _, a := range s.Attrs {
+			span.Attributes = append(span.Attributes, otlpAttr(a))
+		}
+		if s.Err != nil {
+			span.Status = otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{otlpAttr(slog.String("service.name", serviceName))}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+func otlpAttr(a slog.Attr) otlpAttribute {
+	var v otlpValue
+	switch val := a.Value.Resolve(); val.Kind() {
+	case slog.KindInt64:
+		s := strconv.FormatInt(val.Int64(), 10)
+		v.IntValue = &s
+	case slog.KindUint64:
+		s := strconv.FormatUint(val.Uint64(), 10)
+		v.StringValue = &s // may not fit in an int64
+	case slog.KindFloat64:
+		f := val.Float64()
+		v.DoubleValue = &f
+	case slog.KindBool:
+		b := val.Bool()
+		v.BoolValue = &b
+	default:
+		s := val.String()
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: a.Key, Value: v}
+}
@@ -0,0 +1,137 @@
+// Package tracing records spans around interactions, database queries and
+// Discord REST calls. IDs follow the W3C trace context format so exported
+// spans can be loaded by any OpenTelemetry collector.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+func (id SpanID) IsZero() bool    { return id == SpanID{} }
+
+// Kind tells what a span represents (same values as OTLP)
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2 // handling a request (ex: an interaction)
+	KindClient   Kind = 3 // calling another service (ex: the database)
+)
+
+// Span is a timed operation, part of a trace
+type Span struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // zero for the root of a trace
+	Name     string
+	Kind     Kind
+	Start    time.Time
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []slog.Attr
+	err   error
+}
+
+// Start opens a span, child of the one held by ctx if any. The returned
+// context carries the new span: logs written with it get its IDs.
+func Start(ctx context.Context, kind Kind, name string, attrs ...slog.Attr) (context.Context, *Span) {
+	s := &Span{
+		Name:  name,
+		Kind:  kind,
+		Start: time.Now(),
+		attrs: attrs,
+	}
+	rand.Read(s.SpanID[:]) //nolint:errcheck
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		rand.Read(s.TraceID[:]) //nolint:errcheck
+	}
+	return context.WithValue(ctx, util.SpanKey, s), s
+}
+
+// FromContext returns the current span, nil outside of any
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(util.SpanKey).(*Span)
+	return s
+}
+
+// IDs identify the span in logs
+func (s *Span) IDs() (traceID, spanID string) {
+	return s.TraceID.String(), s.SpanID.String()
+}
+
+func (s *Span) SetAttrs(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed. nil errors are ignored so results can
+// be passed along unchecked.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End closes the span and hands it to the exporter. Later calls do nothing.
+func (s *Span) End() {
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	export(s)
+}
+
+// SpanData is a finished span, safe to read without locking
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+	Attrs    []slog.Attr
+	Err      error
+}
+
+func (s *Span) data() SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpanData{
+		TraceID:  s.TraceID,
+		SpanID:   s.SpanID,
+		ParentID: s.ParentID,
+		Name:     s.Name,
+		Kind:     s.Kind,
+		Start:    s.Start,
+		End:      s.end,
+		Attrs:    slices.Clone(s.attrs),
+		Err:      s.err,
+	}
+}
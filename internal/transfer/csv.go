@@ -0,0 +1,347 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+// table describes how one table of the dump maps to a CSV file
+type table struct {
+	file   string
+	header []string
+	rows   func(d *Dump) [][]string
+	load   func(d *Dump, row []string) error
+}
+
+var tables = []table{
+	{
+		file:   "guilds.csv",
+		header: []string{"guild_id", "channel_id", "announcement_channel_id", "admins", "admin_role_id", "timezone", "ruleset"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Guilds))
+			for _, g := range d.Guilds {
+				rows = append(rows, []string{
+					strconv.FormatUint(g.GuildID, 10), strconv.FormatUint(g.ChannelID, 10),
+					formatUint(g.AnnouncementChannelID), database.FormatAdmins(g.Admins),
+					formatUint(g.AdminRoleID), g.Timezone, g.Ruleset,
+				})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			guild, err := strconv.ParseUint(row[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			channel, err := strconv.ParseUint(row[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			announcements, err := parseUint(row[2])
+			if err != nil {
+				return err
+			}
+			adminRole, err := parseUint(row[4])
+			if err != nil {
+				return err
+			}
+			d.Guilds = append(d.Guilds, Guild{
+				GuildID:               guild,
+				ChannelID:             channel,
+				AnnouncementChannelID: announcements,
+				Admins:                database.Guild{Admins: row[3]}.AdminIDs(),
+				AdminRoleID:           adminRole,
+				Timezone:              row[5],
+				Ruleset:               row[6],
+			})
+			return nil
+		},
+	},
+	{
+		file:   "events.csv",
+		header: []string{"event_id", "guild_id", "time", "proof", "verification"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Events))
+			for _, e := range d.Events {
+				rows = append(rows, []string{
+					e.EventID, strconv.FormatUint(e.GuildID, 10),
+					formatTime(e.Time), formatInt(e.Proof), e.Verification,
+				})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			guild, err := strconv.ParseUint(row[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			t, err := parseTime(row[2])
+			if err != nil {
+				return err
+			}
+			proof, err := parseInt(row[3])
+			if err != nil {
+				return err
+			}
+			d.Events = append(d.Events, Event{EventID: row[0], GuildID: guild, Time: t, Proof: proof, Verification: row[4]})
+			return nil
+		},
+	},
+	{
+		file:   "swinces.csv",
+		header: []string{"event_id", "guild_id", "swince_id", "participant_id", "nominee_id", "fulfillment_id"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Swinces))
+			for _, s := range d.Swinces {
+				rows = append(rows, []string{
+					s.EventID, strconv.FormatUint(s.GuildID, 10), s.SwinceID,
+					strconv.FormatUint(s.ParticipantID, 10),
+					formatUint(s.NomineeID), formatString(s.FulfillmentID),
+				})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			guild, err := strconv.ParseUint(row[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			participant, err := strconv.ParseUint(row[3], 10, 64)
+			if err != nil {
+				return err
+			}
+			nominee, err := parseUint(row[4])
+			if err != nil {
+				return err
+			}
+			d.Swinces = append(d.Swinces, Swince{
+				EventID:       row[0],
+				GuildID:       guild,
+				SwinceID:      row[2],
+				ParticipantID: participant,
+				NomineeID:     nominee,
+				FulfillmentID: parseString(row[5]),
+			})
+			return nil
+		},
+	},
+	{
+		file:   "seasons.csv",
+		header: []string{"guild_id", "start_time", "ruleset"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Seasons))
+			for _, s := range d.Seasons {
+				rows = append(rows, []string{strconv.FormatUint(s.GuildID, 10), formatTime(s.StartTime), s.Ruleset})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			guild, err := strconv.ParseUint(row[0], 10, 64)
+			if err != nil {
+				return err
+			}
+			t, err := parseTime(row[1])
+			if err != nil {
+				return err
+			}
+			d.Seasons = append(d.Seasons, Season{GuildID: guild, StartTime: t, Ruleset: row[2]})
+			return nil
+		},
+	},
+	{
+		file:   "archives.csv",
+		header: []string{"event_id", "sha256", "size", "duplicate_of", "reviewed"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Archives))
+			for _, a := range d.Archives {
+				rows = append(rows, []string{
+					a.EventID, a.SHA256, strconv.FormatInt(a.Size, 10),
+					formatString(a.DuplicateOf), strconv.FormatBool(a.Reviewed),
+				})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			size, err := strconv.ParseInt(row[2], 10, 64)
+			if err != nil {
+				return err
+			}
+			reviewed, err := strconv.ParseBool(row[4])
+			if err != nil {
+				return err
+			}
+			d.Archives = append(d.Archives, Archive{
+				EventID:     row[0],
+				SHA256:      row[1],
+				Size:        size,
+				DuplicateOf: parseString(row[3]),
+				Reviewed:    reviewed,
+			})
+			return nil
+		},
+	},
+	{
+		file:   "votes.csv",
+		header: []string{"event_id", "voter_id", "approve"},
+		rows: func(d *Dump) [][]string {
+			rows := make([][]string, 0, len(d.Votes))
+			for _, v := range d.Votes {
+				rows = append(rows, []string{v.EventID, strconv.FormatUint(v.VoterID, 10), strconv.FormatBool(v.Approve)})
+			}
+			return rows
+		},
+		load: func(d *Dump, row []string) error {
+			voter, err := strconv.ParseUint(row[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			approve, err := strconv.ParseBool(row[2])
+			if err != nil {
+				return err
+			}
+			d.Votes = append(d.Votes, Vote{EventID: row[0], VoterID: voter, Approve: approve})
+			return nil
+		},
+	},
+}
+
+// WriteCSV writes one CSV file per table inside dir
+func WriteCSV(dir string, d *Dump) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("creating export directory: %w", err)
+	}
+
+	for _, t := range tables {
+		if err := writeTable(filepath.Join(dir, t.file), t.header, t.rows(d)); err != nil {
+			return fmt.Errorf("writing %s: %w", t.file, err)
+		}
+	}
+	return nil
+}
+
+func writeTable(path string, header []string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	w := csv.NewWriter(f)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ReadCSV loads a dump written by WriteCSV. Missing files are treated as
+// empty tables.
+func ReadCSV(dir string) (*Dump, error) {
+	d := &Dump{Version: Version}
+
+	for _, t := range tables {
+		if err := readTable(filepath.Join(dir, t.file), t, d); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", t.file, err)
+		}
+	}
+	return d, nil
+}
+
+func readTable(path string, t table, d *Dump) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(t.header)
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	if !slices.Equal(records[0], t.header) {
+		return fmt.Errorf("unexpected header %v (expected %v)", records[0], t.header)
+	}
+
+	for i, row := range records[1:] {
+		if err := t.load(d, row); err != nil {
+			return fmt.Errorf("line %d: %w", i+2, err)
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func formatInt(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func parseInt(s string) (*int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func formatUint(n *uint64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatUint(*n, 10)
+}
+
+func parseUint(s string) (*uint64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func formatString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func parseString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,305 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+// Version of the dump format. Bump it whenever a field is added or its
+// meaning changes so older dumps can be detected on import.
+const Version = 3
+
+// Dump is a complete copy of the swincebot history
+type Dump struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Guilds     []Guild   `json:"guilds"`
+	Events     []Event   `json:"events"`
+	Swinces    []Swince  `json:"swinces"`
+	Seasons    []Season  `json:"seasons"`
+	Archives   []Archive `json:"archives"`
+	Votes      []Vote    `json:"votes"`
+}
+
+type Guild struct {
+	GuildID               uint64   `json:"guild_id"`
+	ChannelID             uint64   `json:"channel_id"`
+	AnnouncementChannelID *uint64  `json:"announcement_channel_id,omitempty"`
+	Admins                []uint64 `json:"admins,omitempty"`
+	AdminRoleID           *uint64  `json:"admin_role_id,omitempty"`
+	Timezone              string   `json:"timezone"`
+	Ruleset               string   `json:"ruleset"`
+}
+
+type Event struct {
+	EventID      string    `json:"event_id"`
+	GuildID      uint64    `json:"guild_id"`
+	Time         time.Time `json:"time"`
+	Proof        *int64    `json:"proof,omitempty"`
+	Verification string    `json:"verification"`
+}
+
+type Swince struct {
+	EventID       string  `json:"event_id"`
+	GuildID       uint64  `json:"guild_id"`
+	SwinceID      string  `json:"swince_id"`
+	ParticipantID uint64  `json:"participant_id"`
+	NomineeID     *uint64 `json:"nominee_id,omitempty"`
+	FulfillmentID *string `json:"fulfillment_id,omitempty"`
+}
+
+type Season struct {
+	GuildID   uint64    `json:"guild_id"`
+	StartTime time.Time `json:"start_time"`
+	Ruleset   string    `json:"ruleset"`
+}
+
+type Archive struct {
+	EventID     string  `json:"event_id"`
+	SHA256      string  `json:"sha256"`
+	Size        int64   `json:"size"`
+	DuplicateOf *string `json:"duplicate_of,omitempty"`
+	Reviewed    bool    `json:"reviewed"`
+}
+
+type Vote struct {
+	EventID string `json:"event_id"`
+	VoterID uint64 `json:"voter_id"`
+	Approve bool   `json:"approve"`
+}
+
+// Export reads every table into a Dump
+func Export(ctx context.Context, db *database.ProtoDB) (*Dump, error) {
+	d := &Dump{
+		Version:    Version,
+		ExportedAt: time.Now().UTC(),
+	}
+
+	guilds, err := db.ListGuilds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading guilds: %w", err)
+	}
+	for _, g := range guilds {
+		d.Guilds = append(d.Guilds, Guild{
+			GuildID:               g.GuildID,
+			ChannelID:             g.ChannelID,
+			AnnouncementChannelID: g.AnnouncementChannelID,
+			Admins:                g.AdminIDs(),
+			AdminRoleID:           g.AdminRoleID,
+			Timezone:              g.Timezone,
+			Ruleset:               g.Ruleset,
+		})
+	}
+
+	events, err := db.GetEvents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+	for _, e := range events {
+		d.Events = append(d.Events, Event{
+			EventID:      e.EventID,
+			GuildID:      e.GuildID,
+			Time:         e.Time,
+			Proof:        fromNullInt(e.Proof),
+			Verification: e.Verification,
+		})
+	}
+
+	swinces, err := db.ListSwinces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading swinces: %w", err)
+	}
+	for _, s := range swinces {
+		d.Swinces = append(d.Swinces, Swince{
+			EventID:       s.EventID,
+			GuildID:       s.GuildID,
+			SwinceID:      s.SwinceID,
+			ParticipantID: s.ParticipantID,
+			NomineeID:     s.NomineeID,
+			FulfillmentID: fromNullString(s.FulfillmentID),
+		})
+	}
+
+	seasons, err := db.ListSeasons(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading seasons: %w", err)
+	}
+	for _, s := range seasons {
+		d.Seasons = append(d.Seasons, Season(s))
+	}
+
+	archives, err := db.ListArchives(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading archives: %w", err)
+	}
+	for _, a := range archives {
+		d.Archives = append(d.Archives, Archive{
+			EventID:     a.EventID,
+			SHA256:      a.Sha256,
+			Size:        a.Size,
+			DuplicateOf: fromNullString(a.DuplicateOf),
+			Reviewed:    a.Reviewed != 0,
+		})
+	}
+
+	votes, err := db.ListVotes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading votes: %w", err)
+	}
+	for _, v := range votes {
+		d.Votes = append(d.Votes, Vote{
+			EventID: v.EventID,
+			VoterID: v.VoterID,
+			Approve: v.Approve != 0,
+		})
+	}
+
+	return d, nil
+}
+
+// Import validates the dump against itself and the current database content,
+// then writes it inside a single transaction. With dryRun, the transaction is
+// rolled back so nothing is persisted but every database constraint is still
+// exercised.
+func Import(ctx context.Context, db *database.ProtoDB, d *Dump, dryRun bool) error {
+	if err := Validate(ctx, db, d); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	q := db.WithTx(tx)
+
+	for _, g := range d.Guilds {
+		err := q.UpsertGuild(ctx, database.UpsertGuildParams{
+			GuildID:               g.GuildID,
+			ChannelID:             g.ChannelID,
+			AnnouncementChannelID: g.AnnouncementChannelID,
+			Admins:                database.FormatAdmins(g.Admins),
+			AdminRoleID:           g.AdminRoleID,
+			Timezone:              g.Timezone,
+			Ruleset:               g.Ruleset,
+		})
+		if err != nil {
+			return fmt.Errorf("importing guild %d: %w", g.GuildID, err)
+		}
+	}
+
+	for _, e := range d.Events {
+		err := q.ImportEvent(ctx, database.ImportEventParams{
+			EventID:      e.EventID,
+			GuildID:      e.GuildID,
+			Time:         e.Time,
+			Proof:        toNullInt(e.Proof),
+			Verification: e.Verification,
+		})
+		if err != nil {
+			return fmt.Errorf("importing event %s: %w", e.EventID, err)
+		}
+	}
+
+	// Fulfillment links point to other swinces: insert them all before linking
+	for _, s := range d.Swinces {
+		err := q.CreateSwince(ctx, database.CreateSwinceParams{
+			EventID:       s.EventID,
+			GuildID:       s.GuildID,
+			SwinceID:      s.SwinceID,
+			ParticipantID: s.ParticipantID,
+			NomineeID:     s.NomineeID,
+		})
+		if err != nil {
+			return fmt.Errorf("importing swince %s: %w", s.SwinceID, err)
+		}
+	}
+	for _, s := range d.Swinces {
+		if s.FulfillmentID == nil {
+			continue
+		}
+		err := q.FulfillNomination(ctx, database.FulfillNominationParams{
+			FulfillmentID: toNullString(s.FulfillmentID),
+			SwinceID:      s.SwinceID,
+		})
+		if err != nil {
+			return fmt.Errorf("linking fulfillment of swince %s: %w", s.SwinceID, err)
+		}
+	}
+
+	for _, s := range d.Seasons {
+		if err := q.CreateSeason(ctx, database.CreateSeasonParams(s)); err != nil {
+			return fmt.Errorf("importing season %s of guild %d: %w", s.StartTime, s.GuildID, err)
+		}
+	}
+
+	for _, a := range d.Archives {
+		var reviewed int64
+		if a.Reviewed {
+			reviewed = 1
+		}
+		err := q.ImportArchive(ctx, database.ImportArchiveParams{
+			EventID:     a.EventID,
+			Sha256:      a.SHA256,
+			Size:        a.Size,
+			DuplicateOf: toNullString(a.DuplicateOf),
+			Reviewed:    reviewed,
+		})
+		if err != nil {
+			return fmt.Errorf("importing archive of event %s: %w", a.EventID, err)
+		}
+	}
+
+	for _, v := range d.Votes {
+		var approve int64
+		if v.Approve {
+			approve = 1
+		}
+		err := q.CastVote(ctx, database.CastVoteParams{
+			EventID: v.EventID,
+			VoterID: v.VoterID,
+			Approve: approve,
+		})
+		if err != nil {
+			return fmt.Errorf("importing vote on event %s: %w", v.EventID, err)
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+	return tx.Commit()
+}
+
+func fromNullInt(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+func toNullInt(n *int64) sql.NullInt64 {
+	if n == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *n, Valid: true}
+}
+
+func fromNullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func toNullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
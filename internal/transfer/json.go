@@ -0,0 +1,24 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+func WriteJSON(w io.Writer, d *Dump) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+func ReadJSON(r io.Reader) (*Dump, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	d := &Dump{}
+	if err := dec.Decode(d); err != nil {
+		return nil, fmt.Errorf("decoding JSON dump: %w", err)
+	}
+	return d, nil
+}
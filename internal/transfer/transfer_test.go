@@ -0,0 +1,222 @@
+package transfer
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+	"github.com/ChausseBenjamin/swincebot/internal/util"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// testDump covers every table, with a fulfilled nomination, an open one and
+// an archive flagged as a duplicate
+func testDump() *Dump {
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return &Dump{
+		Version: Version,
+		Guilds: []Guild{
+			{GuildID: 1, ChannelID: 10, Admins: []uint64{100}, Timezone: "UTC", Ruleset: "v1"},
+			{GuildID: 2, ChannelID: 20, AnnouncementChannelID: ptr[uint64](21), AdminRoleID: ptr[uint64](22), Timezone: "UTC", Ruleset: "v1"},
+		},
+		Events: []Event{
+			{EventID: "e1", GuildID: 1, Time: day, Proof: ptr[int64](1001), Verification: database.VerificationApproved},
+			{EventID: "e2", GuildID: 1, Time: day.Add(time.Hour), Proof: ptr[int64](1002), Verification: database.VerificationPending},
+			{EventID: "e3", GuildID: 2, Time: day.Add(2 * time.Hour), Verification: database.VerificationRejected},
+		},
+		Swinces: []Swince{
+			{EventID: "e1", GuildID: 1, SwinceID: "s1", ParticipantID: 100, NomineeID: ptr[uint64](200), FulfillmentID: ptr("s2")},
+			{EventID: "e2", GuildID: 1, SwinceID: "s2", ParticipantID: 200, NomineeID: ptr[uint64](300)},
+			{EventID: "e3", GuildID: 2, SwinceID: "s3", ParticipantID: 400},
+		},
+		Seasons: []Season{
+			{GuildID: 1, StartTime: day.Add(-24 * time.Hour), Ruleset: "v1"},
+			{GuildID: 2, StartTime: day.Add(-24 * time.Hour), Ruleset: "v1"},
+		},
+		Archives: []Archive{
+			{EventID: "e1", SHA256: "aa", Size: 42},
+			{EventID: "e2", SHA256: "aa", Size: 42, DuplicateOf: ptr("e1"), Reviewed: true},
+		},
+		Votes: []Vote{
+			{EventID: "e2", VoterID: 100, Approve: true},
+			{EventID: "e2", VoterID: 300, Approve: false},
+		},
+	}
+}
+
+func newTestDB(t *testing.T) *database.ProtoDB {
+	t.Helper()
+	db, err := database.Setup(context.Background(), filepath.Join(t.TempDir(), "swincebot.db"), &util.ConfigStore{DBCacheSize: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	return db
+}
+
+// export dumps db in a stable order so dumps can be compared
+func export(t *testing.T, db *database.ProtoDB) *Dump {
+	t.Helper()
+	d, err := Export(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	d.ExportedAt = time.Time{}
+	slices.SortFunc(d.Events, func(a, b Event) int { return strings.Compare(a.EventID, b.EventID) })
+	slices.SortFunc(d.Swinces, func(a, b Swince) int { return strings.Compare(a.SwinceID, b.SwinceID) })
+	slices.SortFunc(d.Archives, func(a, b Archive) int { return strings.Compare(a.EventID, b.EventID) })
+	slices.SortFunc(d.Votes, func(a, b Vote) int { return cmp.Compare(a.VoterID, b.VoterID) })
+	return d
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	want := testDump()
+
+	src := newTestDB(t)
+	if err := Import(ctx, src, want, false); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	exported := export(t, src)
+	if !reflect.DeepEqual(exported, want) {
+		t.Fatalf("exported dump differs from the imported one:\ngot  %+v\nwant %+v", exported, want)
+	}
+
+	formats := []struct {
+		name string
+		copy func(d *Dump) (*Dump, error)
+	}{
+		{"JSON", func(d *Dump) (*Dump, error) {
+			var buf bytes.Buffer
+			if err := WriteJSON(&buf, d); err != nil {
+				return nil, err
+			}
+			return ReadJSON(&buf)
+		}},
+		{"CSV", func(d *Dump) (*Dump, error) {
+			dir := t.TempDir()
+			if err := WriteCSV(dir, d); err != nil {
+				return nil, err
+			}
+			return ReadCSV(dir)
+		}},
+	}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			d, err := f.copy(exported)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dst := newTestDB(t)
+			if err := Import(ctx, dst, d, false); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if got := export(t, dst); !reflect.DeepEqual(got, want) {
+				t.Errorf("dump differs after going through %s:\ngot  %+v\nwant %+v", f.name, got, want)
+			}
+		})
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	if err := Import(ctx, db, testDump(), true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if got := export(t, db); len(got.Guilds)+len(got.Events)+len(got.Swinces)+len(got.Seasons)+len(got.Archives)+len(got.Votes) > 0 {
+		t.Fatalf("dry run persisted rows: %+v", got)
+	}
+
+	// Nothing was left behind to collide with
+	if err := Import(ctx, db, testDump(), false); err != nil {
+		t.Fatalf("Import after a dry run: %v", err)
+	}
+
+}
+
+func TestImportRejectsInvalidDumps(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(d *Dump)
+		problem string
+	}{
+		{
+			name:    "dangling fulfillment",
+			edit:    func(d *Dump) { d.Swinces[1].FulfillmentID = ptr("missing") },
+			problem: "swince s2 is fulfilled by unknown swince missing",
+		},
+		{
+			name:    "fulfillment without a nominee",
+			edit:    func(d *Dump) { d.Swinces[2].FulfillmentID = ptr("s1") },
+			problem: "swince s3 is fulfilled but nominated no-one",
+		},
+		{
+			name:    "swince of an unknown event",
+			edit:    func(d *Dump) { d.Swinces[2].EventID = "missing" },
+			problem: "swince s3 references unknown event missing",
+		},
+		{
+			name:    "swince in another guild than its event",
+			edit:    func(d *Dump) { d.Swinces[2].GuildID = 1 },
+			problem: "swince s3 belongs to guild 1 but its event to guild 2",
+		},
+		{
+			name:    "duplicate of an unknown event",
+			edit:    func(d *Dump) { d.Archives[1].DuplicateOf = ptr("missing") },
+			problem: "archive of event e2 is a duplicate of unknown event missing",
+		},
+		{
+			name:    "unknown verification state",
+			edit:    func(d *Dump) { d.Events[0].Verification = "maybe" },
+			problem: `event e1 has an unknown verification state "maybe"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := newTestDB(t)
+			d := testDump()
+			tt.edit(d)
+
+			err := Import(ctx, db, d, false)
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Import returned %v, want a ValidationError", err)
+			}
+			if !slices.Contains(invalid.Problems, tt.problem) {
+				t.Errorf("problems %q don't include %q", invalid.Problems, tt.problem)
+			}
+			if got := export(t, db); len(got.Guilds) > 0 || len(got.Events) > 0 {
+				t.Errorf("an invalid dump was partially imported: %+v", got)
+			}
+		})
+	}
+
+	// Rows of the dump may not collide with the database's
+	ctx := context.Background()
+	db := newTestDB(t)
+	if err := Import(ctx, db, testDump(), false); err != nil {
+		t.Fatal(err)
+	}
+	err := Import(ctx, db, testDump(), false)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || !slices.Contains(invalid.Problems, "swince s1 already exists in the database") {
+		t.Errorf("importing the same dump twice returned %v", err)
+	}
+}
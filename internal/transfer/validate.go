@@ -0,0 +1,241 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ChausseBenjamin/swincebot/internal/database"
+)
+
+// maxReportedProblems keeps validation errors readable on huge dumps
+const maxReportedProblems = 50
+
+// ValidationError lists every problem found in a dump
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("dump is invalid (%d problem(s))", len(e.Problems)))
+	for i, p := range e.Problems {
+		if i == maxReportedProblems {
+			sb.WriteString(fmt.Sprintf("\n- ...and %d more", len(e.Problems)-i))
+			break
+		}
+		sb.WriteString("\n- " + p)
+	}
+	return sb.String()
+}
+
+// Validate checks the dump's version and its referential integrity. References
+// may point to rows in the dump or rows already in the database, but rows of
+// the dump may not collide with existing ones. Guilds are the exception: their
+// configuration overwrites the existing one.
+func Validate(ctx context.Context, db *database.ProtoDB, d *Dump) error {
+	if d.Version != Version {
+		return fmt.Errorf("unsupported dump version %d (expected %d)", d.Version, Version)
+	}
+
+	v := &ValidationError{}
+	problem := func(format string, args ...any) {
+		v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+	}
+
+	existing, err := existingKeys(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	guilds := make(map[uint64]bool, len(d.Guilds))
+	for _, g := range d.Guilds {
+		switch {
+		case g.GuildID == 0:
+			problem("guild with an empty ID")
+		case guilds[g.GuildID]:
+			problem("guild %d appears more than once", g.GuildID)
+		}
+		if g.ChannelID == 0 {
+			problem("guild %d has no swince channel", g.GuildID)
+		}
+		if _, err := time.LoadLocation(g.Timezone); err != nil || g.Timezone == "" {
+			problem("guild %d has an unknown timezone %q", g.GuildID, g.Timezone)
+		}
+		if g.Ruleset == "" {
+			problem("guild %d has no ruleset", g.GuildID)
+		}
+		guilds[g.GuildID] = true
+	}
+	guildExists := func(id uint64) bool { return guilds[id] || existing.guilds[id] }
+
+	events := make(map[string]uint64, len(d.Events)) // event ID -> guild ID
+	for _, e := range d.Events {
+		_, duplicate := events[e.EventID]
+		_, exists := existing.events[e.EventID]
+		switch {
+		case e.EventID == "":
+			problem("event with an empty ID")
+		case duplicate:
+			problem("event %s appears more than once", e.EventID)
+		case exists:
+			problem("event %s already exists in the database", e.EventID)
+		}
+		if !guildExists(e.GuildID) {
+			problem("event %s references unknown guild %d", e.EventID, e.GuildID)
+		}
+		switch e.Verification {
+		case database.VerificationPending, database.VerificationApproved,
+			database.VerificationDisputed, database.VerificationRejected:
+		default:
+			problem("event %s has an unknown verification state %q", e.EventID, e.Verification)
+		}
+		events[e.EventID] = e.GuildID
+	}
+	eventGuild := func(id string) (uint64, bool) {
+		if guildID, exists := events[id]; exists {
+			return guildID, true
+		}
+		guildID, exists := existing.events[id]
+		return guildID, exists
+	}
+	eventExists := func(id string) bool {
+		_, exists := eventGuild(id)
+		return exists
+	}
+
+	swinces := make(map[string]bool, len(d.Swinces))
+	for _, s := range d.Swinces {
+		switch {
+		case s.SwinceID == "":
+			problem("swince with an empty ID")
+		case swinces[s.SwinceID]:
+			problem("swince %s appears more than once", s.SwinceID)
+		case existing.swinces[s.SwinceID]:
+			problem("swince %s already exists in the database", s.SwinceID)
+		}
+		if guildID, exists := eventGuild(s.EventID); !exists {
+			problem("swince %s references unknown event %s", s.SwinceID, s.EventID)
+		} else if guildID != s.GuildID {
+			problem("swince %s belongs to guild %d but its event to guild %d", s.SwinceID, s.GuildID, guildID)
+		}
+		swinces[s.SwinceID] = true
+	}
+	for _, s := range d.Swinces {
+		if s.FulfillmentID == nil {
+			continue
+		}
+		if s.NomineeID == nil {
+			problem("swince %s is fulfilled but nominated no-one", s.SwinceID)
+		}
+		if !swinces[*s.FulfillmentID] && !existing.swinces[*s.FulfillmentID] {
+			problem("swince %s is fulfilled by unknown swince %s", s.SwinceID, *s.FulfillmentID)
+		}
+	}
+
+	seasons := make(map[string]bool, len(d.Seasons))
+	for _, s := range d.Seasons {
+		key := seasonKey(s.GuildID, s.StartTime)
+		switch {
+		case seasons[key]:
+			problem("season starting %s on guild %d appears more than once", s.StartTime, s.GuildID)
+		case existing.seasons[key]:
+			problem("season starting %s on guild %d already exists in the database", s.StartTime, s.GuildID)
+		}
+		if !guildExists(s.GuildID) {
+			problem("season starting %s references unknown guild %d", s.StartTime, s.GuildID)
+		}
+		if s.Ruleset == "" {
+			problem("season starting %s has no ruleset", s.StartTime)
+		}
+		seasons[key] = true
+	}
+
+	archived := make(map[string]bool, len(d.Archives))
+	for _, a := range d.Archives {
+		if archived[a.EventID] {
+			problem("archive of event %s appears more than once", a.EventID)
+		}
+		archived[a.EventID] = true
+		if !eventExists(a.EventID) {
+			problem("archive references unknown event %s", a.EventID)
+		}
+		if a.DuplicateOf != nil && !eventExists(*a.DuplicateOf) {
+			problem("archive of event %s is a duplicate of unknown event %s", a.EventID, *a.DuplicateOf)
+		}
+	}
+
+	votes := make(map[string]bool, len(d.Votes))
+	for _, vote := range d.Votes {
+		key := fmt.Sprintf("%s/%d", vote.EventID, vote.VoterID)
+		if votes[key] {
+			problem("user %d voted more than once on event %s", vote.VoterID, vote.EventID)
+		}
+		votes[key] = true
+		if !eventExists(vote.EventID) {
+			problem("vote references unknown event %s", vote.EventID)
+		}
+	}
+
+	if len(v.Problems) > 0 {
+		return v
+	}
+	return nil
+}
+
+// keys are the primary keys already present in the database
+type keys struct {
+	guilds  map[uint64]bool
+	events  map[string]uint64 // event ID -> guild ID
+	swinces map[string]bool
+	seasons map[string]bool // see seasonKey
+}
+
+func seasonKey(guildID uint64, start time.Time) string {
+	return fmt.Sprintf("%d/%d", guildID, start.UnixNano())
+}
+
+// existingKeys collects the primary keys already present in the database
+func existingKeys(ctx context.Context, db *database.ProtoDB) (keys, error) {
+	k := keys{
+		guilds:  make(map[uint64]bool),
+		events:  make(map[string]uint64),
+		swinces: make(map[string]bool),
+		seasons: make(map[string]bool),
+	}
+
+	dbGuilds, err := db.ListGuilds(ctx)
+	if err != nil {
+		return keys{}, fmt.Errorf("reading guilds: %w", err)
+	}
+	for _, g := range dbGuilds {
+		k.guilds[g.GuildID] = true
+	}
+
+	dbEvents, err := db.GetEvents(ctx)
+	if err != nil {
+		return keys{}, fmt.Errorf("reading events: %w", err)
+	}
+	for _, e := range dbEvents {
+		k.events[e.EventID] = e.GuildID
+	}
+
+	dbSwinces, err := db.ListSwinces(ctx)
+	if err != nil {
+		return keys{}, fmt.Errorf("reading swinces: %w", err)
+	}
+	for _, s := range dbSwinces {
+		k.swinces[s.SwinceID] = true
+	}
+
+	dbSeasons, err := db.ListSeasons(ctx)
+	if err != nil {
+		return keys{}, fmt.Errorf("reading seasons: %w", err)
+	}
+	for _, s := range dbSeasons {
+		k.seasons[seasonKey(s.GuildID, s.StartTime)] = true
+	}
+
+	return k, nil
+}
@@ -17,6 +17,9 @@ type ContextKey uint8
 const (
 	DBKey ContextKey = iota
 	ReqIDKey
+	ClaimsKey
+	ConfigKey
+	SpanKey
 )
 
 type ParseUUIDParams struct {
@@ -27,8 +30,13 @@ type ParseUUIDParams struct {
 }
 
 type ConfigStore struct {
-	Admins      []uint64
 	DBCacheSize int
+
+	// Server which owned everything before the bot served several of them,
+	// needed to migrate databases of that time
+	SeedGuildID   uint64
+	SeedChannelID uint64
+	SeedAdmins    string
 }
 
 func GetFromContext[T any](ctx context.Context, key any) *T {
@@ -6,45 +6,269 @@ from events
 -- name: GetNextSeasonStart :one
 select start_time
 from seasons
-where start_time > ?
+where guild_id = ? and start_time > ?
 order by start_time asc
 limit 1;
 
 -- name: GetSeasonStart :one
 select start_time
 from seasons
+where guild_id = ?
 order by start_time asc
 limit 1 offset ?;
 
 -- name: GetSeasonID :one
 select count(*) as season_id
 from seasons
-where start_time <= ?;
+where guild_id = ? and start_time <= ?;
 
--- name: GetUserSwinces :many
-select s.*, e.time
+-- name: GetSwincesBetween :many
+select s.*, e.time, e.verification, n.swince_id as fulfills
 from swinces s
 join events e on s.event_id = e.event_id
-where s.participant_id = ? and e.time >= ? and e.time < ?;
+left join swinces n on n.fulfillment_id = s.swince_id
+where e.guild_id = ? and e.time >= ? and e.time < ?
+order by e.time asc;
 
--- name: GetUserNominations :many
-select s.*, e.time
+-- name: CreateEvent :exec
+insert into events (event_id, guild_id, time, proof)
+values (?, ?, ?, ?);
+
+-- name: CreateSwince :exec
+insert into swinces (event_id, guild_id, swince_id, participant_id, nominee_id)
+values (?, ?, ?, ?, ?);
+
+-- name: GetOpenNomination :one
+select s.*
+from swinces s
+join events e on s.event_id = e.event_id
+where s.guild_id = ? and s.nominee_id = ? and s.fulfillment_id is null and s.event_id != ?
+    and e.verification != 'rejected'
+order by e.time asc
+limit 1;
+
+-- name: FulfillNomination :exec
+update swinces
+set fulfillment_id = ?
+where swince_id = ?;
+
+-- name: GetArchiveByHash :one
+select a.*
+from archives a
+join events e on a.event_id = e.event_id
+where e.guild_id = ? and a.sha256 = ? and a.event_id != ?
+order by a.rowid asc
+limit 1;
+
+-- name: CreateArchive :exec
+insert into archives (event_id, sha256, size, duplicate_of)
+values (?, ?, ?, ?);
+
+-- name: ListSuspectedDuplicates :many
+select pa.event_id, pa.duplicate_of, pa.sha256, e.time, e.proof, d.proof as original_proof
+from archives pa
+join events e on pa.event_id = e.event_id
+left join events d on pa.duplicate_of = d.event_id
+where e.guild_id = ? and pa.duplicate_of is not null and pa.reviewed = 0
+order by e.time asc;
+
+-- name: MarkDuplicateReviewed :execrows
+update archives
+set reviewed = 1
+where archives.event_id = ?
+  and archives.event_id in (select e.event_id from events e where e.guild_id = ?);
+
+-- name: GetEvent :one
+select *
+from events
+where event_id = ?;
+
+-- name: GetEventParticipants :many
+select participant_id
+from swinces
+where event_id = ?;
+
+-- name: SetEventVerification :exec
+update events
+set verification = ?
+where event_id = ?;
+
+-- name: ReopenNominations :exec
+update swinces
+set fulfillment_id = null
+where fulfillment_id in (select r.swince_id from swinces r where r.event_id = ?);
+
+-- name: CastVote :exec
+insert into votes (event_id, voter_id, approve)
+values (?, ?, ?)
+on conflict (event_id, voter_id) do update set approve = excluded.approve;
+
+-- name: CountVotes :one
+select cast(coalesce(sum(approve), 0) as integer) as approvals,
+       cast(count(*) - coalesce(sum(approve), 0) as integer) as disputes
+from votes
+where event_id = ?;
+
+-- name: ListDisputedEvents :many
+select e.*,
+       cast((select count(*) from votes v where v.event_id = e.event_id and v.approve = 0) as integer) as disputes
+from events e
+where e.guild_id = ? and e.verification = 'disputed'
+order by e.time asc;
+
+-- name: ListSwinces :many
+select *
+from swinces;
+
+-- name: ListSeasons :many
+select *
+from seasons
+order by guild_id, start_time asc;
+
+-- name: ListGuildSeasons :many
+select *
+from seasons
+where guild_id = ?
+order by start_time asc;
+
+-- name: ListArchives :many
+select *
+from archives;
+
+-- name: ListVotes :many
+select *
+from votes;
+
+-- name: ImportEvent :exec
+insert into events (event_id, guild_id, time, proof, verification)
+values (?, ?, ?, ?, ?);
+
+-- name: CreateSeason :exec
+insert into seasons (guild_id, start_time, ruleset)
+values (?, ?, ?);
+
+-- name: ImportArchive :exec
+insert into archives (event_id, sha256, size, duplicate_of, reviewed)
+values (?, ?, ?, ?, ?);
+
+-- name: ListEventsPage :many
+select *
+from events
+where guild_id = ?
+order by time desc
+limit ? offset ?;
+
+-- name: CountEvents :one
+select count(*)
+from events
+where guild_id = ?;
+
+-- name: ListEventSwinces :many
+select *
+from swinces
+where event_id = ?;
+
+-- name: CreateToken :exec
+insert into tokens (token_id, user_id, scopes, issued_at, expires_at)
+values (?, ?, ?, ?, ?);
+
+-- name: GetToken :one
+select *
+from tokens
+where token_id = ?;
+
+-- name: ListUserTokens :many
+select *
+from tokens
+where user_id = ?
+  and revoked_at is null
+  and expires_at > ?
+order by issued_at desc;
+
+-- name: RevokeToken :execrows
+update tokens
+set revoked_at = ?
+where token_id = ?
+  and revoked_at is null;
+
+-- name: GetGuild :one
+select *
+from guilds
+where guild_id = ?;
+
+-- name: ListGuilds :many
+select *
+from guilds
+order by guild_id asc;
+
+-- name: UpsertGuild :exec
+insert into guilds (guild_id, channel_id, announcement_channel_id, admins, admin_role_id, timezone, ruleset)
+values (?, ?, ?, ?, ?, ?, ?)
+on conflict (guild_id) do update set
+  channel_id = excluded.channel_id,
+  announcement_channel_id = excluded.announcement_channel_id,
+  admins = excluded.admins,
+  admin_role_id = excluded.admin_role_id,
+  timezone = excluded.timezone,
+  ruleset = excluded.ruleset;
+
+-- name: DeleteGuild :execrows
+delete from guilds
+where guild_id = ?;
+
+-- name: DeleteEvent :execrows
+delete from events
+where event_id = ?;
+
+-- name: UnlinkEventFulfillments :exec
+update swinces
+set fulfillment_id = null
+where fulfillment_id in (select f.swince_id from swinces f where f.event_id = ?);
+
+-- name: ListBrokenFulfillments :many
+select s.swince_id, s.fulfillment_id,
+       cast(case
+         when f.swince_id is null then 'missing'
+         when f.guild_id != s.guild_id then 'other-guild'
+         when s.nominee_id is null then 'no-nominee'
+         else 'wrong-participant'
+       end as text) as problem
+from swinces s
+left join swinces f on f.swince_id = s.fulfillment_id
+where s.fulfillment_id is not null
+  and (f.swince_id is null
+    or f.guild_id != s.guild_id
+    or s.nominee_id is null
+    or f.participant_id != s.nominee_id);
+
+-- name: ListOpenNominations :many
+select s.guild_id, e.time
 from swinces s
 join events e on s.event_id = e.event_id
-where s.participant_id = ? and s.nominee_id is not null and s.fulfillment_id is not null
-  and e.time >= ? and e.time < ?;
+where s.nominee_id is not null and s.fulfillment_id is null and e.time >= ?;
 
--- name: GetUserFulfillments :many
-select s.*, e.time
+-- name: CountOverdueNominations :many
+select s.guild_id, count(*) as nominations
 from swinces s
 join events e on s.event_id = e.event_id
-where s.participant_id = ? and s.swince_id in (
-  select fulfillment_id from swinces where fulfillment_id is not null
-) and e.time >= ? and e.time < ?;
+where s.nominee_id is not null and s.fulfillment_id is null and e.time < ?
+group by s.guild_id;
 
--- name: GetAllUsersInSeason :many
-select distinct participant_id
+-- name: ListDueReminders :many
+select s.swince_id, s.participant_id, s.nominee_id, e.time, g.channel_id
 from swinces s
 join events e on s.event_id = e.event_id
-where e.time >= ? and e.time < ?;
+join guilds g on s.guild_id = g.guild_id
+left join outboundmessages o on o.idempotency_key = 'reminder:' || s.swince_id
+where s.nominee_id is not null and s.fulfillment_id is null
+    and e.verification != 'rejected'
+    and e.time > ? and e.time <= ?
+    and o.idempotency_key is null;
+
+-- name: GetOutboundMessage :one
+select * from outboundmessages where idempotency_key = ?;
 
+-- name: InsertOutboundMessage :exec
+insert into outboundmessages (idempotency_key, channel_id, message_id, sent_at)
+values (?, ?, ?, ?)
+on conflict (idempotency_key) do nothing;